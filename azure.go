@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type azureHostConfig struct {
+	Host   string `json:"host"`
+	APIURL string `json:"api_url,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+func (c *azureHostConfig) host() string {
+	return c.Host
+}
+
+// https://learn.microsoft.com/en-us/rest/api/azure/devops/git/pull-request-threads?view=azure-devops-rest-7.0#commentthreadstatus
+const (
+	azureThreadActive = "active"
+	azureThreadFixed  = "fixed"
+	azureThreadClosed = "closed"
+)
+
+type azureProvider struct {
+	*apiClient
+}
+
+func newAzureProvider(c *azureHostConfig) (*azureProvider, error) {
+	if c.Token == "" {
+		return nil, fmt.Errorf("missing an authentication config for %s", c.Host)
+	}
+
+	apiURL := c.APIURL
+	if apiURL == "" {
+		apiURL = "https://" + c.Host
+	}
+
+	if _, err := url.Parse(apiURL); err != nil {
+		return nil, err
+	}
+
+	// The personal access token is sent with an empty username.
+	return &azureProvider{newAPIClient(apiURL, basicAuth("", c.Token))}, nil
+}
+
+type azureComment struct {
+	ID              int    `json:"id,omitempty"`
+	ParentCommentID int    `json:"parentCommentId,omitempty"`
+	Content         string `json:"content"`
+	CommentType     string `json:"commentType,omitempty"`
+	IsDeleted       bool   `json:"isDeleted,omitempty"`
+}
+
+type azureThread struct {
+	ID       int             `json:"id,omitempty"`
+	Status   string          `json:"status,omitempty"`
+	Comments []*azureComment `json:"comments,omitempty"`
+}
+
+type azureThreads struct {
+	Value []*azureThread `json:"value"`
+}
+
+func (p *azureProvider) parseURL(u *url.URL) (gitURL, error) {
+	return newAzureURL(u)
+}
+
+func (p *azureProvider) maxCommentLength() int {
+	return 150000
+}
+
+// https://learn.microsoft.com/en-us/rest/api/azure/devops/git/pull-request-threads/list?view=azure-devops-rest-7.0
+func (p *azureProvider) listComments(ctx context.Context, u gitURL) ([]*vcsComment, error) {
+	var threads azureThreads
+	if _, err := p.do(ctx, http.MethodGet, p.threadsURL(u), nil, &threads); err != nil {
+		return nil, err
+	}
+	sort.Slice(threads.Value, func(i, j int) bool {
+		return threads.Value[i].ID < threads.Value[j].ID
+	})
+
+	comments := make([]*vcsComment, 0, len(threads.Value))
+	for _, t := range threads.Value {
+		if len(t.Comments) == 0 || t.Comments[0].IsDeleted {
+			continue
+		}
+		comments = append(comments, &vcsComment{
+			ID:       strconv.Itoa(t.Comments[0].ID),
+			ThreadID: strconv.Itoa(t.ID),
+			Body:     t.Comments[0].Content,
+			Hidden:   t.Status == azureThreadClosed,
+		})
+	}
+	return comments, nil
+}
+
+// https://learn.microsoft.com/en-us/rest/api/azure/devops/git/pull-request-threads/create?view=azure-devops-rest-7.0
+func (p *azureProvider) createComment(ctx context.Context, u gitURL, comment *vcsNewComment) error {
+	in := &azureThread{
+		Status: azureThreadStatus(comment),
+		Comments: []*azureComment{
+			{Content: comment.Body, CommentType: "text"},
+		},
+	}
+	_, err := p.do(ctx, http.MethodPost, p.threadsURL(u), in, nil)
+	return err
+}
+
+// https://learn.microsoft.com/en-us/rest/api/azure/devops/git/pull-request-thread-comments/update?view=azure-devops-rest-7.0
+func (p *azureProvider) updateComment(ctx context.Context, u gitURL, comment *vcsComment, update *vcsNewComment) error {
+	in := &azureComment{Content: update.Body}
+	url := p.threadsURL(u, comment.ThreadID, "comments", comment.ID)
+	if _, err := p.do(ctx, http.MethodPatch, url, in, nil); err != nil {
+		return err
+	}
+	if comment.Hidden {
+		return nil
+	}
+
+	// https://learn.microsoft.com/en-us/rest/api/azure/devops/git/pull-request-threads/update?view=azure-devops-rest-7.0
+	_, err := p.do(ctx, http.MethodPatch, p.threadsURL(u, comment.ThreadID), &azureThread{Status: azureThreadStatus(update)}, nil)
+	return err
+}
+
+// https://learn.microsoft.com/en-us/rest/api/azure/devops/git/pull-request-threads/update?view=azure-devops-rest-7.0
+func (p *azureProvider) hideComment(ctx context.Context, u gitURL, comment *vcsComment) error {
+	in := &azureThread{Status: azureThreadClosed}
+	_, err := p.do(ctx, http.MethodPatch, p.threadsURL(u, comment.ThreadID), in, nil)
+	return err
+}
+
+// The thread of the destructive comment is left active so that reviewers need to resolve it.
+func azureThreadStatus(comment *vcsNewComment) string {
+	if comment.Destructive {
+		return azureThreadActive
+	}
+	return azureThreadFixed
+}
+
+func (p *azureProvider) threadsURL(u gitURL, paths ...string) string {
+	var b strings.Builder
+	b.WriteString(p.apiURL)
+	for _, path := range strings.Split(u.Owner(), "/") {
+		b.WriteString("/")
+		b.WriteString(url.PathEscape(path))
+	}
+	fmt.Fprintf(&b, "/_apis/git/repositories/%s/pullRequests/%d/threads", url.PathEscape(u.Repository()), u.PullRequest())
+	for _, path := range paths {
+		b.WriteString("/")
+		b.WriteString(url.PathEscape(path))
+	}
+	b.WriteString("?api-version=7.0")
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureProviderUpdateCommentStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment *vcsComment
+		update  *vcsNewComment
+		status  string
+	}{
+		{name: "destructive", comment: &vcsComment{ID: "1", ThreadID: "10"}, update: &vcsNewComment{Body: "body", Destructive: true}, status: azureThreadActive},
+		{name: "not destructive", comment: &vcsComment{ID: "1", ThreadID: "10"}, update: &vcsNewComment{Body: "body"}, status: azureThreadFixed},
+		{name: "closed", comment: &vcsComment{ID: "1", ThreadID: "10", Hidden: true}, update: &vcsNewComment{Body: "body", Destructive: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				content string
+				status  string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPatch {
+					t.Errorf("unexpected method: %s", r.Method)
+				}
+				switch r.URL.Path {
+				case "/org/project/_apis/git/repositories/repo/pullRequests/1/threads/10/comments/1":
+					var in azureComment
+					json.NewDecoder(r.Body).Decode(&in)
+					content = in.Content
+				case "/org/project/_apis/git/repositories/repo/pullRequests/1/threads/10":
+					var in azureThread
+					json.NewDecoder(r.Body).Decode(&in)
+					status = in.Status
+				default:
+					t.Errorf("unexpected path: %s", r.URL.Path)
+				}
+				w.Write([]byte("{}"))
+			}))
+			defer srv.Close()
+
+			p, err := newAzureProvider(&azureHostConfig{Host: "dev.azure.com", APIURL: srv.URL, Token: "token"})
+			if err != nil {
+				t.Fatalf("failed to create the provider: %v", err)
+			}
+			u := &azureURL{host: "dev.azure.com", project: "org/project", repository: "repo", pullRequest: 1}
+			if err := p.updateComment(context.Background(), u, tt.comment, tt.update); err != nil {
+				t.Fatalf("failed to update the comment: %v", err)
+			}
+			if content != tt.update.Body {
+				t.Errorf("content = %q, want %q", content, tt.update.Body)
+			}
+			if status != tt.status {
+				t.Errorf("status = %q, want %q", status, tt.status)
+			}
+		})
+	}
+}
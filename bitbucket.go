@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type bitbucketHostConfig struct {
+	Host        string `json:"host"`
+	APIURL      string `json:"api_url,omitempty"`
+	Token       string `json:"token,omitempty"`
+	Username    string `json:"username,omitempty"`
+	AppPassword string `json:"app_password,omitempty"`
+}
+
+func (c *bitbucketHostConfig) host() string {
+	return c.Host
+}
+
+func newBitbucketProvider(c *bitbucketHostConfig) (vcsProvider, error) {
+	if c.Token == "" && (c.Username == "" || c.AppPassword == "") {
+		return nil, fmt.Errorf("missing an authentication config for %s", c.Host)
+	}
+
+	auth := bearerAuth(c.Token)
+	if c.Token == "" {
+		auth = basicAuth(c.Username, c.AppPassword)
+	}
+
+	apiURL := c.APIURL
+	if c.Host == BITBUCKET_HOST {
+		if apiURL == "" {
+			apiURL = "https://api.bitbucket.org/2.0"
+		}
+		return &bitbucketCloudProvider{newAPIClient(apiURL, auth)}, nil
+	}
+
+	if apiURL == "" {
+		apiURL = "https://" + c.Host
+	}
+	return &bitbucketDataCenterProvider{newAPIClient(apiURL, auth)}, nil
+}
+
+// bitbucketMaxCommentLength is kept small, since Bitbucket doesn't document the limit of a comment.
+const bitbucketMaxCommentLength = 32768
+
+type bitbucketCloudProvider struct {
+	*apiClient
+}
+
+type bitbucketCloudComment struct {
+	ID      int  `json:"id,omitempty"`
+	Deleted bool `json:"deleted,omitempty"`
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+}
+
+type bitbucketCloudComments struct {
+	Values []*bitbucketCloudComment `json:"values"`
+	Next   string                   `json:"next"`
+}
+
+func (p *bitbucketCloudProvider) parseURL(u *url.URL) (gitURL, error) {
+	return newBitbucketURL(u)
+}
+
+func (p *bitbucketCloudProvider) maxCommentLength() int {
+	return bitbucketMaxCommentLength
+}
+
+// https://developer.atlassian.com/cloud/bitbucket/rest/api-group-pullrequests/#api-repositories-workspace-repo-slug-pullrequests-pull-request-id-comments-get
+func (p *bitbucketCloudProvider) listComments(ctx context.Context, u gitURL) ([]*vcsComment, error) {
+	var comments []*vcsComment
+	for next := p.commentsURL(u) + "?pagelen=100"; next != ""; {
+		var page bitbucketCloudComments
+		if _, err := p.do(ctx, http.MethodGet, next, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, c := range page.Values {
+			comments = append(comments, &vcsComment{
+				ID:     strconv.Itoa(c.ID),
+				Body:   c.Content.Raw,
+				Hidden: c.Deleted,
+			})
+		}
+		next = page.Next
+	}
+	return comments, nil
+}
+
+func (p *bitbucketCloudProvider) createComment(ctx context.Context, u gitURL, comment *vcsNewComment) error {
+	in := &bitbucketCloudComment{}
+	in.Content.Raw = comment.Body
+	_, err := p.do(ctx, http.MethodPost, p.commentsURL(u), in, nil)
+	return err
+}
+
+func (p *bitbucketCloudProvider) updateComment(ctx context.Context, u gitURL, comment *vcsComment, update *vcsNewComment) error {
+	in := &bitbucketCloudComment{}
+	in.Content.Raw = update.Body
+	_, err := p.do(ctx, http.MethodPut, p.commentsURL(u)+"/"+comment.ID, in, nil)
+	return err
+}
+
+// A comment cannot be hidden on Bitbucket, so that the outdated one is deleted instead.
+func (p *bitbucketCloudProvider) hideComment(ctx context.Context, u gitURL, comment *vcsComment) error {
+	_, err := p.do(ctx, http.MethodDelete, p.commentsURL(u)+"/"+comment.ID, nil, nil)
+	return err
+}
+
+func (p *bitbucketCloudProvider) commentsURL(u gitURL) string {
+	return fmt.Sprintf("%s/repositories/%s/%s/pullrequests/%d/comments",
+		p.apiURL, url.PathEscape(u.Owner()), url.PathEscape(u.Repository()), u.PullRequest())
+}
+
+type bitbucketDataCenterProvider struct {
+	*apiClient
+}
+
+type bitbucketDataCenterComment struct {
+	ID       int                           `json:"id,omitempty"`
+	Version  int                           `json:"version"`
+	Text     string                        `json:"text"`
+	Comments []*bitbucketDataCenterComment `json:"comments,omitempty"`
+}
+
+const bitbucketOutdatedComment = "_This comment is outdated._"
+
+type bitbucketDataCenterActivities struct {
+	Values []*struct {
+		Action        string                      `json:"action"`
+		CommentAction string                      `json:"commentAction"`
+		Comment       *bitbucketDataCenterComment `json:"comment"`
+	} `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+func (p *bitbucketDataCenterProvider) parseURL(u *url.URL) (gitURL, error) {
+	return newBitbucketURL(u)
+}
+
+func (p *bitbucketDataCenterProvider) maxCommentLength() int {
+	return bitbucketMaxCommentLength
+}
+
+// https://developer.atlassian.com/server/bitbucket/rest/v811/api-group-pull-requests/#api-api-latest-projects-projectkey-repos-repositoryslug-pull-requests-pullrequestid-activities-get
+func (p *bitbucketDataCenterProvider) listComments(ctx context.Context, u gitURL) ([]*vcsComment, error) {
+	var comments []*vcsComment
+	for start, last := 0, false; !last; {
+		var page bitbucketDataCenterActivities
+		url := fmt.Sprintf("%s/activities?limit=100&start=%d", p.pullRequestURL(u), start)
+		if _, err := p.do(ctx, http.MethodGet, url, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, a := range page.Values {
+			if a.Action != "COMMENTED" || a.CommentAction != "ADDED" || a.Comment == nil {
+				continue
+			}
+			comments = append(comments, &vcsComment{
+				ID:      strconv.Itoa(a.Comment.ID),
+				Body:    a.Comment.Text,
+				Version: a.Comment.Version,
+			})
+		}
+		start, last = page.NextPageStart, page.IsLastPage
+	}
+
+	// The activities are listed from the newest one.
+	for i, j := 0, len(comments)-1; i < j; i, j = i+1, j-1 {
+		comments[i], comments[j] = comments[j], comments[i]
+	}
+	return comments, nil
+}
+
+func (p *bitbucketDataCenterProvider) createComment(ctx context.Context, u gitURL, comment *vcsNewComment) error {
+	in := &bitbucketDataCenterComment{Text: comment.Body}
+	_, err := p.do(ctx, http.MethodPost, p.pullRequestURL(u)+"/comments", in, nil)
+	return err
+}
+
+func (p *bitbucketDataCenterProvider) updateComment(ctx context.Context, u gitURL, comment *vcsComment, update *vcsNewComment) error {
+	in := &bitbucketDataCenterComment{Text: update.Body, Version: comment.Version}
+	_, err := p.do(ctx, http.MethodPut, p.pullRequestURL(u)+"/comments/"+comment.ID, in, nil)
+	return err
+}
+
+func (p *bitbucketDataCenterProvider) hideComment(ctx context.Context, u gitURL, comment *vcsComment) error {
+	err := p.deleteComment(ctx, u, comment.ID, comment.Version)
+	var se *statusError
+	if !errors.As(err, &se) || se.status != http.StatusConflict {
+		return err
+	}
+
+	// The conflict is returned for the outdated version, or for the comment having replies.
+	var current bitbucketDataCenterComment
+	if _, err := p.do(ctx, http.MethodGet, p.pullRequestURL(u)+"/comments/"+comment.ID, nil, &current); err != nil {
+		return err
+	}
+	if len(current.Comments) == 0 {
+		return p.deleteComment(ctx, u, comment.ID, current.Version)
+	}
+	in := &bitbucketDataCenterComment{Text: bitbucketOutdatedComment, Version: current.Version}
+	_, err = p.do(ctx, http.MethodPut, p.pullRequestURL(u)+"/comments/"+comment.ID, in, nil)
+	return err
+}
+
+func (p *bitbucketDataCenterProvider) deleteComment(ctx context.Context, u gitURL, id string, version int) error {
+	url := fmt.Sprintf("%s/comments/%s?version=%d", p.pullRequestURL(u), id, version)
+	_, err := p.do(ctx, http.MethodDelete, url, nil, nil)
+	return err
+}
+
+func (p *bitbucketDataCenterProvider) pullRequestURL(u gitURL) string {
+	return fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/pull-requests/%d",
+		p.apiURL, url.PathEscape(u.Owner()), url.PathEscape(u.Repository()), u.PullRequest())
+}
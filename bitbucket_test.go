@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeBitbucketCloud is a stand-in of the Bitbucket Cloud API serving the comments of a pull request.
+type fakeBitbucketCloud struct {
+	t        *testing.T
+	comments []*bitbucketCloudComment
+	perPage  int
+}
+
+const fakeBitbucketCloudCommentsPath = "/repositories/workspace/repo/pullrequests/5/comments"
+
+func (f *fakeBitbucketCloud) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "password" {
+		f.t.Errorf("basic auth = %q, %q, want the app password", u, p)
+	}
+
+	if !strings.HasPrefix(r.URL.Path, fakeBitbucketCloudCommentsPath) {
+		f.t.Errorf("unexpected path: %s", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, fakeBitbucketCloudCommentsPath), "/")
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		page := 1
+		if v := r.URL.Query().Get("page"); v != "" {
+			page, _ = strconv.Atoi(v)
+		}
+		start, end := (page-1)*f.perPage, page*f.perPage
+		out := &bitbucketCloudComments{}
+		if end < len(f.comments) {
+			out.Next = fmt.Sprintf("http://%s%s?pagelen=100&page=%d", r.Host, r.URL.Path, page+1)
+		} else {
+			end = len(f.comments)
+		}
+		out.Values = f.comments[start:end]
+		json.NewEncoder(w).Encode(out)
+	case r.Method == http.MethodPost && id == "":
+		var in bitbucketCloudComment
+		json.NewDecoder(r.Body).Decode(&in)
+		in.ID = len(f.comments) + 1
+		f.comments = append(f.comments, &in)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&in)
+	case r.Method == http.MethodPut:
+		c := f.comment(id)
+		if c == nil {
+			http.NotFound(w, r)
+			return
+		}
+		var in bitbucketCloudComment
+		json.NewDecoder(r.Body).Decode(&in)
+		c.Content.Raw = in.Content.Raw
+		json.NewEncoder(w).Encode(c)
+	case r.Method == http.MethodDelete:
+		c := f.comment(id)
+		if c == nil {
+			http.NotFound(w, r)
+			return
+		}
+		c.Deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeBitbucketCloud) comment(id string) *bitbucketCloudComment {
+	for _, c := range f.comments {
+		if strconv.Itoa(c.ID) == id && !c.Deleted {
+			return c
+		}
+	}
+	return nil
+}
+
+func newTestBitbucketCloudProvider(t *testing.T, f *fakeBitbucketCloud) vcsProvider {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	p, err := newBitbucketProvider(&bitbucketHostConfig{Host: BITBUCKET_HOST, APIURL: srv.URL, Username: "user", AppPassword: "password"})
+	if err != nil {
+		t.Fatalf("failed to create the provider: %v", err)
+	}
+	if _, ok := p.(*bitbucketCloudProvider); !ok {
+		t.Fatalf("provider = %T, want the Bitbucket Cloud one", p)
+	}
+	return p
+}
+
+func TestBitbucketCloudProviderListCommentsPaginates(t *testing.T) {
+	f := &fakeBitbucketCloud{t: t, perPage: 2}
+	for i := 1; i <= 5; i++ {
+		c := &bitbucketCloudComment{ID: i, Deleted: i == 2}
+		c.Content.Raw = fmt.Sprintf("comment %d", i)
+		f.comments = append(f.comments, c)
+	}
+	p := newTestBitbucketCloudProvider(t, f)
+
+	comments, err := p.listComments(context.Background(), &bitbucketURL{host: BITBUCKET_HOST, owner: "workspace", repository: "repo", pullRequest: 5})
+	if err != nil {
+		t.Fatalf("failed to list the comments: %v", err)
+	}
+	if len(comments) != 5 {
+		t.Fatalf("comments = %d, want 5", len(comments))
+	}
+	for i, c := range comments {
+		want := &vcsComment{ID: fmt.Sprint(i + 1), Body: fmt.Sprintf("comment %d", i+1), Hidden: i == 1}
+		if *c != *want {
+			t.Errorf("comments[%d] = %+v, want %+v", i, c, want)
+		}
+	}
+}
+
+func TestBitbucketCloudProviderComment(t *testing.T) {
+	f := &fakeBitbucketCloud{t: t, perPage: 100}
+	p := newTestBitbucketCloudProvider(t, f)
+	ctx, u := context.Background(), &bitbucketURL{host: BITBUCKET_HOST, owner: "workspace", repository: "repo", pullRequest: 5}
+
+	if err := p.createComment(ctx, u, &vcsNewComment{Body: "first"}); err != nil {
+		t.Fatalf("failed to create the comment: %v", err)
+	}
+	comments, err := p.listComments(ctx, u)
+	if err != nil {
+		t.Fatalf("failed to list the comments: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Body != "first" {
+		t.Fatalf("comments = %+v, want the created one", comments)
+	}
+
+	if err := p.updateComment(ctx, u, comments[0], &vcsNewComment{Body: "edited"}); err != nil {
+		t.Fatalf("failed to update the comment: %v", err)
+	}
+	if got := f.comments[0].Content.Raw; got != "edited" {
+		t.Errorf("body = %q, want %q", got, "edited")
+	}
+
+	if err := p.hideComment(ctx, u, comments[0]); err != nil {
+		t.Fatalf("failed to hide the comment: %v", err)
+	}
+	if !f.comments[0].Deleted {
+		t.Error("the comment is not deleted")
+	}
+
+	if err := p.updateComment(ctx, u, &vcsComment{ID: "99"}, &vcsNewComment{Body: "body"}); err == nil {
+		t.Error("updating the missing comment succeeded")
+	}
+}
+
+// fakeBitbucketDataCenter is a stand-in of the Bitbucket Data Center API serving the comments of a pull request.
+// The comments are locked by their versions, and the ones having replies cannot be deleted.
+type fakeBitbucketDataCenter struct {
+	t        *testing.T
+	comments []*bitbucketDataCenterComment
+	perPage  int
+}
+
+const fakeBitbucketDataCenterPath = "/rest/api/1.0/projects/KEY/repos/slug/pull-requests/8"
+
+func (f *fakeBitbucketDataCenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if got := r.Header.Get("Authorization"); got != "Bearer token" {
+		f.t.Errorf("Authorization = %q, want %q", got, "Bearer token")
+	}
+
+	if !strings.HasPrefix(r.URL.Path, fakeBitbucketDataCenterPath) {
+		f.t.Errorf("unexpected path: %s", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+	rest := strings.Split(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, fakeBitbucketDataCenterPath), "/"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && rest[0] == "activities":
+		f.listActivities(w, r)
+	case r.Method == http.MethodPost && rest[0] == "comments" && len(rest) == 1:
+		var in bitbucketDataCenterComment
+		json.NewDecoder(r.Body).Decode(&in)
+		in.ID = len(f.comments) + 1
+		f.comments = append(f.comments, &in)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&in)
+	case rest[0] == "comments" && len(rest) == 2:
+		c := f.comment(rest[1])
+		if c == nil {
+			http.NotFound(w, r)
+			return
+		}
+		f.serveComment(w, r, c)
+	default:
+		f.t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeBitbucketDataCenter) listActivities(w http.ResponseWriter, r *http.Request) {
+	// The activities are listed from the newest one, including the ones other than the comments.
+	var activities []map[string]interface{}
+	for i := len(f.comments) - 1; i >= 0; i-- {
+		activities = append(activities,
+			map[string]interface{}{"action": "COMMENTED", "commentAction": "ADDED", "comment": f.comments[i]},
+			map[string]interface{}{"action": "APPROVED"},
+		)
+	}
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+	end := start + f.perPage
+	out := map[string]interface{}{"isLastPage": end >= len(activities), "nextPageStart": end}
+	if end > len(activities) {
+		end = len(activities)
+	}
+	out["values"] = activities[start:end]
+	json.NewEncoder(w).Encode(out)
+}
+
+func (f *fakeBitbucketDataCenter) serveComment(w http.ResponseWriter, r *http.Request, c *bitbucketDataCenterComment) {
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(c)
+	case http.MethodPut:
+		var in bitbucketDataCenterComment
+		json.NewDecoder(r.Body).Decode(&in)
+		if in.Version != c.Version {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		c.Text = in.Text
+		c.Version++
+		json.NewEncoder(w).Encode(c)
+	case http.MethodDelete:
+		if r.URL.Query().Get("version") != strconv.Itoa(c.Version) || len(c.Comments) > 0 {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		for i, other := range f.comments {
+			if other == c {
+				f.comments = append(f.comments[:i], f.comments[i+1:]...)
+				break
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeBitbucketDataCenter) comment(id string) *bitbucketDataCenterComment {
+	for _, c := range f.comments {
+		if strconv.Itoa(c.ID) == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func newTestBitbucketDataCenterProvider(t *testing.T, f *fakeBitbucketDataCenter) vcsProvider {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	p, err := newBitbucketProvider(&bitbucketHostConfig{Host: "bitbucket.example.com", APIURL: srv.URL, Token: "token"})
+	if err != nil {
+		t.Fatalf("failed to create the provider: %v", err)
+	}
+	if _, ok := p.(*bitbucketDataCenterProvider); !ok {
+		t.Fatalf("provider = %T, want the Bitbucket Data Center one", p)
+	}
+	return p
+}
+
+var testBitbucketDataCenterURL = &bitbucketURL{host: "bitbucket.example.com", owner: "KEY", repository: "slug", pullRequest: 8}
+
+func TestBitbucketDataCenterProviderListCommentsPaginates(t *testing.T) {
+	f := &fakeBitbucketDataCenter{t: t, perPage: 3}
+	for i := 1; i <= 5; i++ {
+		f.comments = append(f.comments, &bitbucketDataCenterComment{ID: i, Version: i % 2, Text: fmt.Sprintf("comment %d", i)})
+	}
+	p := newTestBitbucketDataCenterProvider(t, f)
+
+	comments, err := p.listComments(context.Background(), testBitbucketDataCenterURL)
+	if err != nil {
+		t.Fatalf("failed to list the comments: %v", err)
+	}
+	if len(comments) != 5 {
+		t.Fatalf("comments = %d, want 5", len(comments))
+	}
+	// The comments are listed in the order they were posted.
+	for i, c := range comments {
+		want := &vcsComment{ID: fmt.Sprint(i + 1), Body: fmt.Sprintf("comment %d", i+1), Version: (i + 1) % 2}
+		if *c != *want {
+			t.Errorf("comments[%d] = %+v, want %+v", i, c, want)
+		}
+	}
+}
+
+func TestBitbucketDataCenterProviderComment(t *testing.T) {
+	f := &fakeBitbucketDataCenter{t: t, perPage: 100}
+	p := newTestBitbucketDataCenterProvider(t, f)
+	ctx, u := context.Background(), testBitbucketDataCenterURL
+
+	if err := p.createComment(ctx, u, &vcsNewComment{Body: "first"}); err != nil {
+		t.Fatalf("failed to create the comment: %v", err)
+	}
+	comments, err := p.listComments(ctx, u)
+	if err != nil {
+		t.Fatalf("failed to list the comments: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Body != "first" {
+		t.Fatalf("comments = %+v, want the created one", comments)
+	}
+
+	if err := p.updateComment(ctx, u, comments[0], &vcsNewComment{Body: "edited"}); err != nil {
+		t.Fatalf("failed to update the comment: %v", err)
+	}
+	if got := f.comments[0].Text; got != "edited" {
+		t.Errorf("body = %q, want %q", got, "edited")
+	}
+	// The version of the listed comment is outdated by the update.
+	if err := p.updateComment(ctx, u, comments[0], &vcsNewComment{Body: "conflicted"}); err == nil {
+		t.Error("updating the outdated version succeeded")
+	}
+
+	// The outdated version is refreshed to delete the comment.
+	if err := p.hideComment(ctx, u, comments[0]); err != nil {
+		t.Fatalf("failed to hide the comment: %v", err)
+	}
+	if len(f.comments) != 0 {
+		t.Errorf("comments = %+v, want the comment deleted", f.comments)
+	}
+}
+
+func TestBitbucketDataCenterProviderHideCommentWithReplies(t *testing.T) {
+	f := &fakeBitbucketDataCenter{t: t, perPage: 100}
+	f.comments = []*bitbucketDataCenterComment{{
+		ID:       1,
+		Version:  2,
+		Text:     "plan",
+		Comments: []*bitbucketDataCenterComment{{ID: 2, Text: "reply"}},
+	}}
+	p := newTestBitbucketDataCenterProvider(t, f)
+
+	if err := p.hideComment(context.Background(), testBitbucketDataCenterURL, &vcsComment{ID: "1", Version: 2}); err != nil {
+		t.Fatalf("failed to hide the comment: %v", err)
+	}
+	if len(f.comments) != 1 || f.comments[0].Text != bitbucketOutdatedComment {
+		t.Errorf("comments = %+v, want the comment replaced with the outdated note", f.comments)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Terraform only reports the failed checks as warnings during the plan.
+func failedChecks(checks []tfjson.CheckResultStatic) []tfjson.CheckResultStatic {
+	var failed []tfjson.CheckResultStatic
+	for _, c := range checks {
+		if c.Status == tfjson.CheckStatusFail || c.Status == tfjson.CheckStatusError {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// The alert lists the first failed checks, since all of them are listed in the Checks section.
+const (
+	maxAlertChecks        = 5
+	maxAlertAddressLength = 100
+)
+
+func renderCheckAlert(checks []tfjson.CheckResultStatic) string {
+	failed := failedChecks(checks)
+	if len(failed) == 0 {
+		return ""
+	}
+
+	addresses := make([]string, 0, maxAlertChecks+1)
+	for _, c := range failed[:min(len(failed), maxAlertChecks)] {
+		addresses = append(addresses, "`"+truncate(c.Address.ToDisplay, maxAlertAddressLength)+"`")
+	}
+	if len(failed) > maxAlertChecks {
+		addresses = append(addresses, fmt.Sprintf("and %d more", len(failed)-maxAlertChecks))
+	}
+	return fmt.Sprintf("> [!WARNING]\n> %d %s failed: %s\n", len(failed), plural(len(failed), "check"), strings.Join(addresses, ", "))
+}
+
+func renderChecks(checks []tfjson.CheckResultStatic) string {
+	var b strings.Builder
+	for _, c := range checks {
+		fmt.Fprintf(&b, "- **%s** `%s`\n", c.Status, c.Address.ToDisplay)
+		for _, problem := range checkProblems(c) {
+			fmt.Fprintf(&b, "  - %s\n", problem)
+		}
+	}
+	return b.String()
+}
+
+// An errored instance has no message, since its errors are only reported as the diagnostics of the run.
+func checkProblems(c tfjson.CheckResultStatic) []string {
+	var problems []string
+	for _, i := range c.Instances {
+		if i.Status != tfjson.CheckStatusFail && i.Status != tfjson.CheckStatusError {
+			continue
+		}
+
+		if len(i.Problems) == 0 {
+			problems = append(problems, fmt.Sprintf("`%s`: %s", i.Address.ToDisplay, i.Status))
+			continue
+		}
+		for _, p := range i.Problems {
+			// The message is kept on a line so that it doesn't break the list.
+			message := strings.Join(strings.Fields(p.Message), " ")
+			problems = append(problems, fmt.Sprintf("`%s`: %s", i.Address.ToDisplay, message))
+		}
+	}
+	return problems
+}
+
+func checkOutcome(checks []tfjson.CheckResultStatic, runURL string) *TFERunTasksResponseOutcome {
+	failed := failedChecks(checks)
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return &TFERunTasksResponseOutcome{
+		Type: "task-result-outcomes",
+		Attributes: &TFERunTasksResponseOutcomesData{
+			OutcomeID:   "checks",
+			Description: fmt.Sprintf("%d %s failed", len(failed), plural(len(failed), "check")),
+			Body:        renderChecks(failed),
+			URL:         runURL,
+			Tags: map[string][]*TFERunTasksResponseOutcomesTags{
+				"Status": {{Label: "Failed", Level: WARNING}},
+			},
+		},
+	}
+}
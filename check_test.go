@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+func TestCheckAlertAndOutcome(t *testing.T) {
+	check := func(address string, status tfjson.CheckStatus, instances ...tfjson.CheckResultDynamic) tfjson.CheckResultStatic {
+		return tfjson.CheckResultStatic{Address: tfjson.CheckStaticAddress{ToDisplay: address}, Status: status, Instances: instances}
+	}
+	instance := func(address string, status tfjson.CheckStatus, messages ...string) tfjson.CheckResultDynamic {
+		i := tfjson.CheckResultDynamic{Address: tfjson.CheckDynamicAddress{ToDisplay: address}, Status: status}
+		for _, m := range messages {
+			i.Problems = append(i.Problems, tfjson.CheckResultProblem{Message: m})
+		}
+		return i
+	}
+	var many []tfjson.CheckResultStatic
+	for i := 0; i < 7; i++ {
+		many = append(many, check(fmt.Sprintf("check.c%d", i), tfjson.CheckStatusFail))
+	}
+
+	tests := []struct {
+		name    string
+		checks  []tfjson.CheckResultStatic
+		alert   string
+		outcome string
+	}{
+		{
+			name:   "passed and unknown",
+			checks: []tfjson.CheckResultStatic{check("check.ok", tfjson.CheckStatusPass), check("check.later", tfjson.CheckStatusUnknown)},
+		},
+		{
+			name: "failed and errored",
+			checks: []tfjson.CheckResultStatic{
+				check("check.ok", tfjson.CheckStatusPass),
+				check("check.health", tfjson.CheckStatusFail,
+					instance("check.health", tfjson.CheckStatusFail, "The service\n  is down.", "The port is closed."),
+				),
+				check("null_resource.web", tfjson.CheckStatusError,
+					instance("null_resource.web[0]", tfjson.CheckStatusPass),
+					instance("null_resource.web[1]", tfjson.CheckStatusError),
+				),
+			},
+			alert: "> [!WARNING]\n> 2 checks failed: `check.health`, `null_resource.web`\n",
+			outcome: "- **fail** `check.health`\n" +
+				"  - `check.health`: The service is down.\n" +
+				"  - `check.health`: The port is closed.\n" +
+				"- **error** `null_resource.web`\n" +
+				"  - `null_resource.web[1]`: error\n",
+		},
+		{
+			name:   "too many failed",
+			checks: many,
+			alert:  "> [!WARNING]\n> 7 checks failed: `check.c0`, `check.c1`, `check.c2`, `check.c3`, `check.c4`, and 2 more\n",
+			outcome: "- **fail** `check.c0`\n- **fail** `check.c1`\n- **fail** `check.c2`\n- **fail** `check.c3`\n" +
+				"- **fail** `check.c4`\n- **fail** `check.c5`\n- **fail** `check.c6`\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderCheckAlert(tt.checks); got != tt.alert {
+				t.Errorf("alert = %q, want %q", got, tt.alert)
+			}
+
+			o := checkOutcome(tt.checks, "https://app.terraform.io/runs/run-1")
+			if tt.outcome == "" {
+				if o != nil {
+					t.Errorf("outcome = %+v, want nil", o.Attributes)
+				}
+				return
+			}
+			if o == nil {
+				t.Fatal("missing the outcome")
+			}
+			if o.Attributes.Body != tt.outcome {
+				t.Errorf("outcome body = %q, want %q", o.Attributes.Body, tt.outcome)
+			}
+			if !strings.HasSuffix(o.Attributes.Description, "failed") {
+				t.Errorf("outcome description = %q", o.Attributes.Description)
+			}
+		})
+	}
+}
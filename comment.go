@@ -0,0 +1,706 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/knanao/runtasks-pr-comment/metadata"
+)
+
+// commentHeaderRoom is left for the summary and the table of the split comments.
+const commentHeaderRoom = 2000
+
+// commentSection is never split across comments.
+type commentSection struct {
+	address string
+	body    string
+}
+
+type commentOptions struct {
+	groupBy string
+	// viewerURL is empty when the plan viewer is disabled.
+	viewerURL string
+	// state is nil when it is unknown.
+	state     *planState
+	maxLength int
+}
+
+// makeIssueComment links the details too long for a comment to the plan viewer if enabled, or splits them into the following comments.
+func makeIssueComment(plan *tfjson.Plan, req *TFERunTasksRequest, guardrails *guardrailReport, opts *commentOptions) ([]string, error) {
+	const (
+		title     = `### Terraform Cloud/Enterprise Plan Output`
+		noChanges = "```\nNo changes. Your infrastructure matches the configuration.\n```"
+		// The errored plan may have stopped before planning any changes.
+		noPlannedChanges = "```\nNo changes were planned.\n```"
+		changeDetails    = "<details>\n<summary>%s</summary>\n\n```diff\n%s\n```\n</details>"
+		configDetails    = "\n\n<details>\n<summary>Generated configuration of %s</summary>\n\n```hcl\n%s\n```\n</details>"
+		exceededChange   = "The change is too long, so please directly check it on TFC/E."
+		checksHeading    = "#### Checks\n\n"
+		exceededCheck    = "  - The problems are too long, so please directly check them on TFC/E.\n"
+		maxComments      = 10
+		// The addresses in the table are truncated to fit in commentHeaderRoom.
+		maxTableAddressLength = 50
+	)
+
+	cs := summarizeChanges(plan)
+	meta, err := makeCommentMetadata(plan, req, opts.state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make the comment metadata: %w", err)
+	}
+
+	var b strings.Builder
+	writeCommentHeader(&b, req, cs.String(), meta)
+
+	b.WriteString(title)
+	b.WriteString("\n")
+
+	if alert := opts.state.markdown(cs); alert != "" {
+		b.WriteString(alert)
+		b.WriteString("\n")
+	}
+	if guardrails != nil && len(guardrails.violations) > 0 {
+		b.WriteString(guardrails.markdown())
+		b.WriteString("\n")
+	}
+	if alert := renderCheckAlert(plan.Checks); alert != "" {
+		b.WriteString(alert)
+		b.WriteString("\n")
+	}
+
+	changes := plan.ResourceChanges
+	if len(changes) == 0 && len(plan.ResourceDrift) == 0 && len(plan.Checks) == 0 {
+		if opts.state.failed(cs) {
+			b.WriteString(noPlannedChanges)
+		} else {
+			b.WriteString(noChanges)
+		}
+		return []string{b.String()}, nil
+	}
+
+	// The imports and moves without other changes, the deposed objects and the data sources get their own sections.
+	// When grouped by action, the deposed objects and the data sources join their action groups instead.
+	byAction := opts.groupBy == commentGroupByAction
+	var rendered, imported, moved, deposed, reads []*tfjson.ResourceChange
+	for _, c := range changes {
+		if c.Change == nil {
+			b.WriteString(noChanges)
+			return []string{b.String()}, nil
+		}
+
+		switch action := UnmarshalActions(c.Change.Actions); {
+		case isImported(c):
+			imported = append(imported, c)
+		case action == NoOp && isMoved(c):
+			moved = append(moved, c)
+		case action == NoOp:
+		case c.DeposedKey != "" && !byAction:
+			deposed = append(deposed, c)
+		case action == Read && !byAction:
+			reads = append(reads, c)
+		default:
+			rendered = append(rendered, c)
+		}
+	}
+
+	// The header includes the metadata and the alerts.
+	limit := opts.maxLength - utf8.RuneCountInString(b.String()) - commentHeaderRoom
+	details := func(summary, diff string) string {
+		detail := fmt.Sprintf(changeDetails, summary, diff)
+		if utf8.RuneCountInString(detail) > limit {
+			detail = fmt.Sprintf(changeDetails, summary, exceededChange)
+		}
+		return detail
+	}
+
+	groups := groupResourceChanges(rendered, opts.groupBy)
+	for _, g := range []*changeGroup{
+		{heading: "#### Resources to import", changes: imported},
+		{heading: "#### Moved resources", changes: moved},
+		{heading: "#### Deposed objects", changes: deposed},
+		{heading: "#### Data sources read during apply", changes: reads},
+	} {
+		if len(g.changes) > 0 {
+			groups = append(groups, g)
+		}
+	}
+
+	sections := makeDriftSections(plan, details)
+	drifted := len(sections) > 0
+	for _, g := range groups {
+		for i, c := range g.changes {
+			action := UnmarshalActions(c.Change.Actions)
+			detail := details(resourceChangeSummary(c, action), renderResourceChange(c, action))
+			// The generated configuration is kept apart from the diff so that it can be copied as is.
+			if c.Change.GeneratedConfig != "" {
+				config := fmt.Sprintf(configDetails, c.Address, strings.TrimSuffix(c.Change.GeneratedConfig, "\n"))
+				if utf8.RuneCountInString(detail+config) <= limit {
+					detail += config
+				}
+			}
+			// The heading is kept together with the first change of the group across comments.
+			if i == 0 && g.heading != "" {
+				detail = g.heading + "\n\n" + detail
+			}
+			// The planned changes are separated from the drift.
+			if drifted {
+				detail = "#### Terraform will perform the following actions\n\n" + detail
+				drifted = false
+			}
+			sections = append(sections, &commentSection{address: c.Address, body: detail + "\n\n"})
+		}
+	}
+
+	if len(plan.OutputChanges) > 0 {
+		oDiff, oCount := renderOutputChanges(plan.OutputChanges)
+		oSummary := fmt.Sprintf("Outputs %d planned to change", oCount)
+		sections = append(sections, &commentSection{address: "Outputs", body: details(oSummary, oDiff) + "\n\n"})
+	}
+
+	for i, c := range plan.Checks {
+		body := renderChecks([]tfjson.CheckResultStatic{c})
+		if utf8.RuneCountInString(checksHeading+body) > limit {
+			// The first line tells the status of the check.
+			body = strings.SplitAfter(body, "\n")[0] + exceededCheck
+		}
+		if i == 0 {
+			body = checksHeading + body
+		}
+		sections = append(sections, &commentSection{address: c.Address.ToDisplay, body: body})
+	}
+
+	if len(changes) == 0 && opts.state.failed(cs) {
+		b.WriteString(noPlannedChanges)
+	} else if len(changes) == 0 {
+		b.WriteString(noChanges)
+	} else {
+		b.WriteString(fmt.Sprintf("```\n%s\n```", cs.String()))
+	}
+	b.WriteString("\n\n")
+
+	pages := paginateSections(sections, limit)
+	if len(pages) == 1 {
+		for _, section := range pages[0] {
+			b.WriteString(section.body)
+		}
+		return []string{b.String()}, nil
+	}
+
+	if opts.viewerURL != "" {
+		fmt.Fprintf(&b, "The changes are too long for a comment, so please check them in the [full plan](%s).\n", opts.viewerURL)
+		return []string{b.String()}, nil
+	}
+
+	var omitted int
+	if len(pages) >= maxComments {
+		for _, page := range pages[maxComments-1:] {
+			omitted += len(page)
+		}
+		pages = pages[:maxComments-1]
+	}
+	total := len(pages) + 1
+
+	b.WriteString("The changes are too long for a comment, so they are split into the following comments.\n\n")
+	b.WriteString("| Comment | Changes |\n|---------|---------|\n")
+	for i, page := range pages {
+		first, last := truncate(page[0].address, maxTableAddressLength), truncate(page[len(page)-1].address, maxTableAddressLength)
+		changes := "`" + first + "`"
+		if len(page) > 1 {
+			changes = fmt.Sprintf("`%s` … `%s` (%d)", first, last, len(page))
+		}
+		fmt.Fprintf(&b, "| %d/%d | %s |\n", i+2, total, changes)
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&b, "\nThe other %d changes are too many to comment, so please directly check them on TFC/E.\n", omitted)
+	}
+
+	bodies := []string{b.String()}
+	for i, page := range pages {
+		var p strings.Builder
+		writeContinuedCommentHeader(&p, req, i+2, total)
+		fmt.Fprintf(&p, "%s (%d/%d)\n", title, i+2, total)
+		for _, section := range page {
+			p.WriteString(section.body)
+		}
+		bodies = append(bodies, p.String())
+	}
+	return bodies, nil
+}
+
+func resourceChangeSummary(c *tfjson.ResourceChange, action Action) string {
+	switch {
+	case isImported(c):
+		return fmt.Sprintf("%s (imported from %q)", c.Address, importID(c))
+	case action == NoOp && isMoved(c):
+		return fmt.Sprintf("%s → %s", c.PreviousAddress, c.Address)
+	case c.DeposedKey != "":
+		return fmt.Sprintf("%s %s (deposed object %s)", action.Symbol(), c.Address, c.DeposedKey)
+	}
+
+	summary := fmt.Sprintf("%s %s", action.Symbol(), c.Address)
+	if isMoved(c) {
+		summary += fmt.Sprintf(" (moved from %s)", c.PreviousAddress)
+	}
+	if c.Change.Importing != nil {
+		summary += fmt.Sprintf(" (imported from %q)", importID(c))
+	}
+	if reason := replacementReason(c, action); reason != "" {
+		summary += fmt.Sprintf(" (%s)", reason)
+	}
+	return summary
+}
+
+// The drifted attributes relevant to the planned changes are marked, since they are often the cause of them.
+func makeDriftSections(plan *tfjson.Plan, details func(summary, diff string) string) []*commentSection {
+	const heading = "#### Objects have changed outside of Terraform\n\n" +
+		"Terraform detected the following changes made outside of Terraform since the last \"terraform apply\" " +
+		"which may have affected this plan.\n\n"
+
+	relevantPaths := make(map[string][]interface{})
+	for _, a := range plan.RelevantAttributes {
+		path := make([]interface{}, 0, len(a.Attribute))
+		for _, step := range a.Attribute {
+			var v interface{}
+			if err := json.Unmarshal(step, &v); err != nil {
+				break
+			}
+			path = append(path, v)
+		}
+		relevantPaths[a.Resource] = append(relevantPaths[a.Resource], path)
+	}
+
+	var sections []*commentSection
+	for _, c := range plan.ResourceDrift {
+		if c.Change == nil {
+			continue
+		}
+		action := UnmarshalActions(c.Change.Actions)
+		if action == NoOp {
+			continue
+		}
+
+		diff, relevant := renderResourceDrift(c, relevantPaths[c.Address])
+		summary := fmt.Sprintf("%s %s", action.Symbol(), c.Address)
+		if relevant {
+			summary += " (affects the planned changes)"
+		}
+		detail := details(summary, diff)
+		if len(sections) == 0 {
+			detail = heading + detail
+		}
+		sections = append(sections, &commentSection{address: c.Address, body: detail + "\n\n"})
+	}
+	return sections
+}
+
+func renderOutputChanges(outputs map[string]*tfjson.Change) (string, int) {
+	keys := make([]string, 0, len(outputs))
+	for k := range outputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	var count int
+	for _, key := range keys {
+		output := outputs[key]
+		if UnmarshalActions(output.Actions) == NoOp {
+			continue
+		}
+		count++
+
+		b.WriteString(renderOutputChange(key, output))
+		b.WriteString("\n")
+	}
+	return b.String(), count
+}
+
+func paginateSections(sections []*commentSection, limit int) [][]*commentSection {
+	var (
+		pages [][]*commentSection
+		page  []*commentSection
+		size  int
+	)
+	for _, section := range sections {
+		n := utf8.RuneCountInString(section.body)
+		if len(page) > 0 && size+n > limit {
+			pages = append(pages, page)
+			page, size = nil, 0
+		}
+		page = append(page, section)
+		size += n
+	}
+	return append(pages, page)
+}
+
+const (
+	commentTag          = "<!-- runtasks-pr-comment -->"
+	commentWorkspaceTag = "<!-- runtasks-pr-comment-workspace: %s %s -->"
+	commentRunTag       = "<!-- runtasks-pr-comment-run: %s -->"
+	commentEntryTag     = "<!-- runtasks-pr-comment-entry: %s -->"
+	commentPageTag      = "<!-- runtasks-pr-comment-page: %d/%d -->"
+	// commentConfigurationTag tells the configuration summary apart from the plan comments.
+	commentConfigurationTag = "<!-- runtasks-pr-comment-configuration -->"
+)
+
+// The summary is kept in the history of the comment when it is edited by the later run in the sticky mode.
+func writeCommentHeader(b *strings.Builder, req *TFERunTasksRequest, summary, metadata string) {
+	const (
+		tasksBadgeURL = `[![RUN_TASKS](https://img.shields.io/static/v1?label=TFE&message=Run_Tasks&color=success&style=flat)](https://developer.hashicorp.com/terraform/cloud-docs/workspaces/settings/run-tasks)`
+		runBadgeURL   = `[![RUNS](https://img.shields.io/static/v1?label=TFE&message=Run&style=flat)](%s)`
+		description   = `This run task was triggered by %s.`
+		workspace     = `**Workspace:** [%s](%s)`
+		workingDir    = ` · **Working directory:** %s`
+	)
+
+	writeCommentTags(b, req, summary, metadata)
+	b.WriteString(tasksBadgeURL)
+
+	fmt.Fprintf(b, " ")
+	fmt.Fprintf(b, runBadgeURL, req.RunAppURL)
+	b.WriteString("\n\n")
+
+	fmt.Fprintf(b, description, req.VCSCommitURL)
+	b.WriteString("\n")
+	if req.WorkspaceName != "" {
+		fmt.Fprintf(b, workspace, req.WorkspaceName, req.WorkspaceAppURL)
+		if req.WorkspaceWorkingDirectory != "" {
+			fmt.Fprintf(b, workingDir, codeOrEmpty(req.WorkspaceWorkingDirectory))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+}
+
+func writeCommentTags(b *strings.Builder, req *TFERunTasksRequest, summary, metadata string) {
+	b.WriteString(commentTag)
+	b.WriteString("\n")
+	if metadata != "" {
+		b.WriteString(metadata)
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, commentWorkspaceTag, req.WorkspaceID, req.WorkspaceName)
+	b.WriteString("\n")
+	fmt.Fprintf(b, commentRunTag, req.RunID)
+	b.WriteString("\n")
+	fmt.Fprintf(b, commentEntryTag, historyEntry(req, summary))
+	b.WriteString("\n")
+}
+
+func writeContinuedCommentHeader(b *strings.Builder, req *TFERunTasksRequest, page, total int) {
+	b.WriteString(commentTag)
+	b.WriteString("\n")
+	fmt.Fprintf(b, commentWorkspaceTag, req.WorkspaceID, req.WorkspaceName)
+	b.WriteString("\n")
+	fmt.Fprintf(b, commentRunTag, req.RunID)
+	b.WriteString("\n")
+	fmt.Fprintf(b, commentPageTag, page, total)
+	b.WriteString("\n")
+}
+
+// maxMetadataLength is the maximum length of the metadata, beyond which the address lists are dropped.
+const maxMetadataLength = 16384
+
+func makeCommentMetadata(plan *tfjson.Plan, req *TFERunTasksRequest, state *planState) (string, error) {
+	cs := summarizeChanges(plan)
+	m := &metadata.Metadata{
+		Version: metadata.Version,
+		RunID:   req.RunID,
+		RunURL:  req.RunAppURL,
+		Workspace: metadata.Workspace{
+			ID:   req.WorkspaceID,
+			Name: req.WorkspaceName,
+		},
+		Commit:    path.Base(req.VCSCommitURL),
+		CommitURL: req.VCSCommitURL,
+		Summary: metadata.Summary{
+			Add:     cs.Add,
+			Change:  cs.Change,
+			Destroy: cs.Remove,
+			Import:  cs.Import,
+			Replace: cs.Replace,
+			Move:    cs.Move,
+		},
+	}
+	if state != nil {
+		m.PlanHash = state.Hash
+	}
+
+	addrs := &m.Addresses
+	for _, c := range plan.ResourceChanges {
+		if c.Change == nil {
+			continue
+		}
+
+		if c.Change.Importing != nil {
+			addrs.Import = append(addrs.Import, c.Address)
+		}
+		if isMoved(c) {
+			addrs.Move = append(addrs.Move, c.Address)
+		}
+		switch UnmarshalActions(c.Change.Actions) {
+		case Create:
+			addrs.Create = append(addrs.Create, c.Address)
+		case Update:
+			addrs.Update = append(addrs.Update, c.Address)
+		case Delete:
+			addrs.Delete = append(addrs.Delete, c.Address)
+		case DeleteThenCreate, CreateThenDelete:
+			addrs.Replace = append(addrs.Replace, c.Address)
+		case Read:
+			addrs.Read = append(addrs.Read, c.Address)
+		}
+	}
+
+	meta, err := metadata.Format(m)
+	if err != nil || len(meta) <= maxMetadataLength {
+		return meta, err
+	}
+	m.Addresses = metadata.Addresses{}
+	m.Truncated = true
+	return metadata.Format(m)
+}
+
+func historyEntry(req *TFERunTasksRequest, summary string) string {
+	return fmt.Sprintf("`%s` [%s](%s) %s: %s", shortCommit(req.VCSCommitURL), req.RunID, req.RunAppURL, req.RunCreatedAt.UTC().Format(time.RFC3339), summary)
+}
+
+func shortCommit(commitURL string) string {
+	commit := path.Base(commitURL)
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return commit
+}
+
+const (
+	historyStartTag = "<!-- runtasks-pr-comment-history -->"
+	historyEndTag   = "<!-- /runtasks-pr-comment-history -->"
+	maxHistory      = 5
+)
+
+var commentEntryPattern = regexp.MustCompile(`<!-- runtasks-pr-comment-entry: (.*) -->`)
+
+// withHistory appends the summaries of the earlier runs, dropping the oldest ones to fit in maxLength.
+func withHistory(body, previous, runID string, maxLength int) string {
+	var entries []string
+	if !strings.Contains(previous, fmt.Sprintf(commentRunTag, runID)) {
+		if m := commentEntryPattern.FindStringSubmatch(previous); m != nil {
+			entries = append(entries, m[1])
+		}
+	}
+
+	if start := strings.Index(previous, historyStartTag); start >= 0 {
+		history := previous[start+len(historyStartTag):]
+		if end := strings.Index(history, historyEndTag); end >= 0 {
+			history = history[:end]
+		}
+		for _, line := range strings.Split(history, "\n") {
+			if entry, ok := strings.CutPrefix(line, "- "); ok {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	if len(entries) > maxHistory {
+		entries = entries[:maxHistory]
+	}
+	for ; len(entries) > 0; entries = entries[:len(entries)-1] {
+		if withEntries := appendHistory(body, entries); utf8.RuneCountInString(withEntries) <= maxLength {
+			return withEntries
+		}
+	}
+	return body
+}
+
+func appendHistory(body string, entries []string) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(body, "\n"))
+	b.WriteString("\n\n<details>\n<summary>Previous runs</summary>\n\n")
+	b.WriteString(historyStartTag)
+	b.WriteString("\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "- %s\n", entry)
+	}
+	b.WriteString(historyEndTag)
+	b.WriteString("\n</details>")
+	return b.String()
+}
+
+const (
+	applyStatusStartTag = "<!-- runtasks-pr-comment-apply -->"
+	applyStatusEndTag   = "<!-- /runtasks-pr-comment-apply -->"
+)
+
+// maxOutputValueLength is the maximum length of an output value in the apply status, beyond which it is truncated.
+const maxOutputValueLength = 200
+
+// The outputs beyond the limit are omitted, since the apply status is put into the existing comment.
+func makeApplyStatus(appliedAt time.Time, outputs []*TFEStateVersionOutput, limit int) string {
+	const (
+		outputsHeading = "<details>\n<summary>Outputs</summary>\n\n| Name | Value |\n|------|------|\n"
+		outputsEnd     = "</details>\n\n"
+		omittedOutputs = "\nThe other %d outputs are too long to comment, so please directly check them on TFC/E.\n"
+	)
+
+	var b strings.Builder
+	b.WriteString(applyStatusStartTag)
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "> [!NOTE]\n> **Applied** at %s\n\n", appliedAt.UTC().Format(time.RFC3339))
+	end := applyStatusEndTag + "\n\n"
+
+	// The room for the rows leaves the one for the note about the omitted outputs.
+	room := limit - utf8.RuneCountInString(b.String()+outputsHeading+outputsEnd+end+fmt.Sprintf(omittedOutputs, len(outputs)))
+	var (
+		rows    strings.Builder
+		written int
+	)
+	for _, output := range outputs {
+		value := maskedValue
+		if !output.Sensitive {
+			value = truncate(outputValue(output.Value), maxOutputValueLength)
+		}
+		row := fmt.Sprintf("| %s | `%s` |\n", output.Name, strings.ReplaceAll(value, "|", "\\|"))
+		if utf8.RuneCountInString(row) > room {
+			break
+		}
+		rows.WriteString(row)
+		room -= utf8.RuneCountInString(row)
+		written++
+	}
+
+	if written > 0 {
+		b.WriteString(outputsHeading)
+		b.WriteString(rows.String())
+		if omitted := len(outputs) - written; omitted > 0 {
+			fmt.Fprintf(&b, omittedOutputs, omitted)
+		}
+		b.WriteString(outputsEnd)
+	} else if len(outputs) > 0 {
+		b.WriteString("The outputs are too long to comment, so please directly check them on TFC/E.\n\n")
+	}
+
+	b.WriteString(end)
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+func outputValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// withApplyStatus replaces the apply status added by the previous apply if exists.
+func withApplyStatus(body, status string) string {
+	if start := strings.Index(body, applyStatusStartTag); start >= 0 {
+		if end := strings.Index(body[start:], applyStatusEndTag); end >= 0 {
+			rest := strings.TrimLeft(body[start+end+len(applyStatusEndTag):], "\n")
+			return body[:start] + status + rest
+		}
+	}
+
+	if i := strings.Index(body, "\n### "); i >= 0 {
+		return body[:i+1] + status + body[i+1:]
+	}
+	return body + "\n\n" + status
+}
+
+func makeConfigurationComment(summary *configSummary, req *TFERunTasksRequest) string {
+	const (
+		title      = `### Terraform Cloud/Enterprise Configuration Summary`
+		noneFound  = "_None_\n"
+		tableRow   = "| %s | %s | %s |\n"
+		varHeading = "| Name | Type | Required | Description |\n|------|------|------|------|\n"
+	)
+
+	var b strings.Builder
+	writeCommentHeader(&b, req, "Configuration summary", "")
+	b.WriteString(commentConfigurationTag)
+	b.WriteString("\n")
+
+	b.WriteString(title)
+	b.WriteString("\n")
+
+	workingDir := req.WorkspaceWorkingDirectory
+	if workingDir == "" {
+		workingDir = "."
+	}
+	fmt.Fprintf(&b, "The plan has not finished yet. These are the declarations in `%s`.\n\n", workingDir)
+
+	b.WriteString("#### Backend\n")
+	if summary.Backend == "" {
+		b.WriteString(noneFound)
+	} else {
+		fmt.Fprintf(&b, "`%s`\n", summary.Backend)
+	}
+
+	b.WriteString("\n#### Providers\n")
+	if len(summary.Providers) == 0 {
+		b.WriteString(noneFound)
+	} else {
+		b.WriteString("| Name | Source | Version |\n|------|------|------|\n")
+		for _, p := range summary.Providers {
+			fmt.Fprintf(&b, tableRow, tableCell(p.Name), codeOrEmpty(tableCell(p.Source)), codeOrEmpty(tableCell(p.Version)))
+		}
+	}
+
+	b.WriteString("\n#### Modules\n")
+	if len(summary.Modules) == 0 {
+		b.WriteString(noneFound)
+	} else {
+		b.WriteString("| Name | Source | Version |\n|------|------|------|\n")
+		for _, m := range summary.Modules {
+			fmt.Fprintf(&b, tableRow, tableCell(m.Name), codeOrEmpty(tableCell(m.Source)), codeOrEmpty(tableCell(m.Version)))
+		}
+	}
+
+	b.WriteString("\n#### Variables\n")
+	if len(summary.Variables) == 0 {
+		b.WriteString(noneFound)
+	} else {
+		b.WriteString(varHeading)
+		for _, v := range summary.Variables {
+			required := "no"
+			if v.Required {
+				required = "yes"
+			}
+			description := tableCell(v.Description)
+			if v.Sensitive {
+				description = strings.TrimSpace("(sensitive) " + description)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", tableCell(v.Name), codeOrEmpty(tableCell(v.Type)), required, description)
+		}
+	}
+
+	return b.String()
+}
+
+func codeOrEmpty(v string) string {
+	if v == "" {
+		return ""
+	}
+	return "`" + v + "`"
+}
+
+// tableCell keeps the value in a cell of the table, joining its lines and escaping the pipes.
+func tableCell(v string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(v), " "), "|", "\\|")
+}
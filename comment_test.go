@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const testMaxCommentLength = 65536
+
+func TestWithHistoryFitsInComment(t *testing.T) {
+	entry := func(i int) string {
+		return fmt.Sprintf("`abcdef%d` [run-%d](https://app.terraform.io/runs/run-%d) 2024-01-01T00:00:00Z: %s", i, i, i, strings.Repeat("x", 150))
+	}
+	previous := fmt.Sprintf(commentRunTag, "run-0") + "\n" + fmt.Sprintf(commentEntryTag, entry(0)) + "\n" + historyStartTag + "\n"
+	for i := 1; i < maxHistory; i++ {
+		previous += "- " + entry(i) + "\n"
+	}
+	previous += historyEndTag
+
+	tests := []struct {
+		name    string
+		bodyLen int
+		entries int
+	}{
+		{name: "short body keeps all the entries", bodyLen: 1000, entries: maxHistory},
+		{name: "long body drops the oldest entries", bodyLen: testMaxCommentLength - 400, entries: 1},
+		{name: "full body drops the history", bodyLen: testMaxCommentLength - 10, entries: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withHistory(strings.Repeat("a", tt.bodyLen), previous, "run-new", testMaxCommentLength)
+			if n := utf8.RuneCountInString(got); n > testMaxCommentLength {
+				t.Errorf("comment length = %d, exceeds %d", n, testMaxCommentLength)
+			}
+			if n := strings.Count(got, "\n- "); n != tt.entries {
+				t.Errorf("entries = %d, want %d", n, tt.entries)
+			}
+			if tt.entries > 0 && !strings.Contains(got, entry(0)) {
+				t.Error("the latest entry is dropped")
+			}
+		})
+	}
+}
+
+func TestMakeApplyStatusLimit(t *testing.T) {
+	appliedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var outputs []*TFEStateVersionOutput
+	for i := 0; i < 100; i++ {
+		outputs = append(outputs, &TFEStateVersionOutput{Name: fmt.Sprintf("out%d", i), Value: strings.Repeat("v", 1000)})
+	}
+
+	tests := []struct {
+		name    string
+		limit   int
+		rows    int
+		omitted bool
+	}{
+		{name: "all outputs fit", limit: testMaxCommentLength, rows: 100},
+		{name: "some outputs are omitted", limit: 2000, rows: 7, omitted: true},
+		{name: "no output fits", limit: 300, rows: 0, omitted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := makeApplyStatus(appliedAt, outputs, tt.limit)
+			if n := utf8.RuneCountInString(got); n > tt.limit {
+				t.Errorf("status length = %d, exceeds %d", n, tt.limit)
+			}
+			if n := strings.Count(got, "| out"); n != tt.rows {
+				t.Errorf("rows = %d, want %d", n, tt.rows)
+			}
+			if omitted := strings.Contains(got, "too long to comment"); omitted != tt.omitted {
+				t.Errorf("omitted = %v, want %v", omitted, tt.omitted)
+			}
+		})
+	}
+}
+
+func TestMakeApplyStatusMasksSensitiveOutputs(t *testing.T) {
+	outputs := []*TFEStateVersionOutput{{Name: "password", Value: "secret", Sensitive: true}}
+	got := makeApplyStatus(time.Now(), outputs, testMaxCommentLength)
+	if strings.Contains(got, "secret") {
+		t.Errorf("the sensitive output is revealed: %s", got)
+	}
+	if !strings.Contains(got, "| password | `(sensitive value)` |") {
+		t.Errorf("the sensitive output is not masked: %s", got)
+	}
+}
+
+func TestPaginateSections(t *testing.T) {
+	section := func(address string, n int) *commentSection {
+		return &commentSection{address: address, body: strings.Repeat("x", n)}
+	}
+
+	tests := []struct {
+		name     string
+		sections []*commentSection
+		limit    int
+		want     [][]string
+	}{
+		{name: "no sections", limit: 10, want: [][]string{nil}},
+		{
+			name:     "all in a page",
+			sections: []*commentSection{section("a", 3), section("b", 3), section("c", 4)},
+			limit:    10,
+			want:     [][]string{{"a", "b", "c"}},
+		},
+		{
+			name:     "split at the limit",
+			sections: []*commentSection{section("a", 4), section("b", 4), section("c", 4), section("d", 4)},
+			limit:    10,
+			want:     [][]string{{"a", "b"}, {"c", "d"}},
+		},
+		{
+			name:     "oversized section in its own page",
+			sections: []*commentSection{section("a", 4), section("b", 20), section("c", 4)},
+			limit:    10,
+			want:     [][]string{{"a"}, {"b"}, {"c"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := paginateSections(tt.sections, tt.limit)
+			var got [][]string
+			for _, page := range pages {
+				var addresses []string
+				for _, s := range page {
+					addresses = append(addresses, s.address)
+				}
+				got = append(got, addresses)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("pages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeIssueCommentChecksFitInComment(t *testing.T) {
+	const maxLength = 20000
+	check := func(i int, message string) tfjson.CheckResultStatic {
+		address := fmt.Sprintf("module.checks.null_resource.check%d", i)
+		c := tfjson.CheckResultStatic{
+			Address: tfjson.CheckStaticAddress{ToDisplay: address},
+			Status:  tfjson.CheckStatusFail,
+		}
+		for j := 0; j < 3; j++ {
+			c.Instances = append(c.Instances, tfjson.CheckResultDynamic{
+				Address:  tfjson.CheckDynamicAddress{ToDisplay: fmt.Sprintf("%s[%d]", address, j)},
+				Status:   tfjson.CheckStatusFail,
+				Problems: []tfjson.CheckResultProblem{{Message: message}},
+			})
+		}
+		return c
+	}
+	plan := &tfjson.Plan{}
+	for i := 0; i < 100; i++ {
+		plan.Checks = append(plan.Checks, check(i, strings.Repeat("problem ", 20)))
+	}
+	plan.Checks = append(plan.Checks, check(100, strings.Repeat("problem ", maxLength)))
+	req := &TFERunTasksRequest{RunID: "run-1", WorkspaceID: "ws-1"}
+
+	comments, err := makeIssueComment(plan, req, nil, &commentOptions{groupBy: commentGroupByAction, maxLength: maxLength})
+	if err != nil {
+		t.Fatalf("failed to make the comment: %v", err)
+	}
+	if len(comments) < 2 {
+		t.Fatalf("comments = %d, want the checks split into multiple comments", len(comments))
+	}
+	for i, body := range comments {
+		if n := utf8.RuneCountInString(body); n > maxLength {
+			t.Errorf("comments[%d] length = %d, exceeds %d", i, n, maxLength)
+		}
+	}
+	if !strings.Contains(comments[0], "101 checks failed") || !strings.Contains(comments[0], "and 96 more") {
+		t.Errorf("the check alert is not capped in:\n%s", comments[0][:min(len(comments[0]), 2000)])
+	}
+	last := comments[len(comments)-1]
+	if !strings.Contains(last, "`module.checks.null_resource.check100`") || !strings.Contains(last, "The problems are too long") {
+		t.Errorf("the oversized check is not replaced in:\n%s", last)
+	}
+}
+
+func TestMakeConfigurationCommentEscapesCells(t *testing.T) {
+	summary := &configSummary{
+		Variables: []*configVariable{
+			{Name: "mode", Type: "string", Description: "a | b\nor c", Required: true},
+			{Name: "token", Type: "object({\n  name = string\n})", Sensitive: true},
+		},
+	}
+	req := &TFERunTasksRequest{RunID: "run-1", WorkspaceID: "ws-1"}
+
+	body := makeConfigurationComment(summary, req)
+	for _, row := range []string{
+		"| mode | `string` | yes | a \\| b or c |\n",
+		"| token | `object({ name = string })` | no | (sensitive) |\n",
+	} {
+		if !strings.Contains(body, row) {
+			t.Errorf("missing the row %q in:\n%s", row, body)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const maxConfigurationFileSize = 10 << 20
+
+// https://developer.hashicorp.com/terraform/cloud-docs/api-docs/configuration-versions#download-configuration-files
+func downloadConfiguration(ctx context.Context, client *http.Client, url, token, workingDir string) (map[string][]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Unexpected status was returned: %d", resp.StatusCode)
+	}
+
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	dir := path.Clean("/" + workingDir)
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		if path.Dir(name) != dir || !isConfigurationFile(name) {
+			continue
+		}
+		if hdr.Size > maxConfigurationFileSize {
+			return nil, fmt.Errorf("%s is too large: %d bytes", hdr.Name, hdr.Size)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Base(name)] = data
+	}
+	return files, nil
+}
+
+func isConfigurationFile(name string) bool {
+	return strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json")
+}
+
+type configSummary struct {
+	Backend   string
+	Providers []*configProvider
+	Modules   []*configModule
+	Variables []*configVariable
+}
+
+type configProvider struct {
+	Name    string
+	Source  string
+	Version string
+}
+
+type configModule struct {
+	Name    string
+	Source  string
+	Version string
+}
+
+type configVariable struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Sensitive   bool
+}
+
+var (
+	rootSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "terraform"},
+			{Type: "provider", LabelNames: []string{"name"}},
+			{Type: "module", LabelNames: []string{"name"}},
+			{Type: "variable", LabelNames: []string{"name"}},
+		},
+	}
+	terraformSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "backend", LabelNames: []string{"type"}},
+			{Type: "cloud"},
+			{Type: "required_providers"},
+		},
+	}
+	moduleSchema = &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "source"},
+			{Name: "version"},
+		},
+	}
+	variableSchema = &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "type"},
+			{Name: "description"},
+			{Name: "default"},
+			{Name: "sensitive"},
+		},
+	}
+)
+
+// The declarations are read statically, so expressions depending on anything else are ignored.
+func inspectConfiguration(files map[string][]byte) (*configSummary, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		parser    = hclparse.NewParser()
+		summary   = &configSummary{}
+		providers = make(map[string]*configProvider)
+	)
+	for _, name := range names {
+		var (
+			file  *hcl.File
+			diags hcl.Diagnostics
+		)
+		if strings.HasSuffix(name, ".json") {
+			file, diags = parser.ParseJSON(files[name], name)
+		} else {
+			file, diags = parser.ParseHCL(files[name], name)
+		}
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		content, _, diags := file.Body.PartialContent(rootSchema)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		for _, block := range content.Blocks {
+			switch block.Type {
+			case "terraform":
+				inspectTerraformBlock(block, summary, providers)
+			case "provider":
+				if _, ok := providers[block.Labels[0]]; !ok {
+					providers[block.Labels[0]] = &configProvider{Name: block.Labels[0]}
+				}
+			case "module":
+				attrs, _, _ := block.Body.PartialContent(moduleSchema)
+				summary.Modules = append(summary.Modules, &configModule{
+					Name:    block.Labels[0],
+					Source:  stringAttribute(attrs.Attributes["source"]),
+					Version: stringAttribute(attrs.Attributes["version"]),
+				})
+			case "variable":
+				attrs, _, _ := block.Body.PartialContent(variableSchema)
+				v := &configVariable{
+					Name:        block.Labels[0],
+					Description: stringAttribute(attrs.Attributes["description"]),
+					Required:    attrs.Attributes["default"] == nil,
+				}
+				if attr, ok := attrs.Attributes["type"]; ok {
+					if ty, diags := typeexpr.TypeConstraint(attr.Expr); !diags.HasErrors() {
+						v.Type = typeexpr.TypeString(ty)
+					}
+				}
+				if attr, ok := attrs.Attributes["sensitive"]; ok {
+					if val, diags := attr.Expr.Value(nil); !diags.HasErrors() && val.Type() == cty.Bool && val.IsKnown() && !val.IsNull() {
+						v.Sensitive = val.True()
+					}
+				}
+				summary.Variables = append(summary.Variables, v)
+			}
+		}
+	}
+
+	for _, p := range providers {
+		summary.Providers = append(summary.Providers, p)
+	}
+	sort.Slice(summary.Providers, func(i, j int) bool {
+		return summary.Providers[i].Name < summary.Providers[j].Name
+	})
+	return summary, nil
+}
+
+func inspectTerraformBlock(block *hcl.Block, summary *configSummary, providers map[string]*configProvider) {
+	content, _, _ := block.Body.PartialContent(terraformSchema)
+	for _, b := range content.Blocks {
+		switch b.Type {
+		case "backend":
+			summary.Backend = b.Labels[0]
+		case "cloud":
+			summary.Backend = "cloud"
+		case "required_providers":
+			attrs, _ := b.Body.JustAttributes()
+			for name, attr := range attrs {
+				p := &configProvider{Name: name}
+				val, diags := attr.Expr.Value(nil)
+				if diags.HasErrors() || !val.IsWhollyKnown() || val.IsNull() {
+					providers[name] = p
+					continue
+				}
+				switch {
+				case val.Type() == cty.String:
+					// The legacy syntax only has the version constraint.
+					p.Version = val.AsString()
+				case val.Type().IsObjectType():
+					if val.Type().HasAttribute("source") {
+						p.Source = ctyString(val.GetAttr("source"))
+					}
+					if val.Type().HasAttribute("version") {
+						p.Version = ctyString(val.GetAttr("version"))
+					}
+				}
+				providers[name] = p
+			}
+		}
+	}
+}
+
+func stringAttribute(attr *hcl.Attribute) string {
+	if attr == nil {
+		return ""
+	}
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return ""
+	}
+	return ctyString(val)
+}
+
+func ctyString(val cty.Value) string {
+	if val.IsNull() || !val.IsKnown() || val.Type() != cty.String {
+		return ""
+	}
+	return val.AsString()
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testConfigurationTarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: "modules/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(files[name]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloadConfiguration(t *testing.T) {
+	tarball := testConfigurationTarball(t, map[string]string{
+		"./main.tf":                "root",
+		"README.md":                "readme",
+		"modules/app/main.tf":      "app",
+		"modules/app/vars.tf.json": "{}",
+		"modules/app/sub/sub.tf":   "sub",
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer token")
+		}
+		if r.URL.Path != "/download" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(tarball)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		url        string
+		workingDir string
+		want       map[string]string
+		wantErr    bool
+	}{
+		{name: "root", url: srv.URL + "/download", want: map[string]string{"main.tf": "root"}},
+		{
+			name:       "working directory",
+			url:        srv.URL + "/download",
+			workingDir: "modules/app/",
+			want:       map[string]string{"main.tf": "app", "vars.tf.json": "{}"},
+		},
+		{name: "missing working directory", url: srv.URL + "/download", workingDir: "missing", want: map[string]string{}},
+		{name: "not found", url: srv.URL + "/missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := downloadConfiguration(context.Background(), srv.Client(), tt.url, "token", tt.workingDir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("downloadConfiguration() = %v, want an error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := make(map[string]string, len(files))
+			for name, data := range files {
+				got[name] = string(data)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspectConfiguration(t *testing.T) {
+	files := map[string][]byte{
+		"main.tf": []byte(`
+terraform {
+  backend "s3" {}
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+    legacy = "1.0"
+  }
+}
+
+provider "google" {}
+
+provider "aws" {
+  region = var.region
+}
+
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.1.0"
+}
+
+module "local" {
+  source = "./modules/local"
+}
+`),
+		"variables.tf.json": []byte(`{
+  "variable": {
+    "region": {
+      "type": "string",
+      "description": "The region\nto deploy",
+      "default": "us-east-1"
+    }
+  }
+}`),
+		"secrets.tf": []byte(`
+variable "token" {
+  type      = map(object({ name = string }))
+  sensitive = true
+}
+`),
+	}
+
+	got, err := inspectConfiguration(files)
+	if err != nil {
+		t.Fatalf("failed to inspect the configuration: %v", err)
+	}
+	want := &configSummary{
+		Backend: "s3",
+		Providers: []*configProvider{
+			{Name: "aws", Source: "hashicorp/aws", Version: "~> 5.0"},
+			{Name: "google"},
+			{Name: "legacy", Version: "1.0"},
+		},
+		Modules: []*configModule{
+			{Name: "vpc", Source: "terraform-aws-modules/vpc/aws", Version: "5.1.0"},
+			{Name: "local", Source: "./modules/local"},
+		},
+		Variables: []*configVariable{
+			{Name: "token", Type: "map(object({name=string}))", Required: true, Sensitive: true},
+			{Name: "region", Type: "string", Description: "The region\nto deploy"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summary = %s, want %s", formatConfigSummary(got), formatConfigSummary(want))
+	}
+
+	if _, err := inspectConfiguration(map[string][]byte{"main.tf": []byte(`module "broken" {`)}); err == nil {
+		t.Error("inspecting the invalid configuration succeeded")
+	}
+}
+
+func formatConfigSummary(s *configSummary) string {
+	var b strings.Builder
+	b.WriteString("backend: " + s.Backend + "\n")
+	for _, p := range s.Providers {
+		b.WriteString("provider: " + p.Name + " " + p.Source + " " + p.Version + "\n")
+	}
+	for _, m := range s.Modules {
+		b.WriteString("module: " + m.Name + " " + m.Source + " " + m.Version + "\n")
+	}
+	for _, v := range s.Variables {
+		b.WriteString("variable: " + v.Name + " " + v.Type + " " + v.Description + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/google/go-github/v56/github"
+	"github.com/shurcooL/githubv4"
+	"golang.org/x/oauth2"
+)
+
+type githubHostConfig struct {
+	Host              string `json:"host"`
+	APIURL            string `json:"api_url,omitempty"`
+	GraphQLURL        string `json:"graphql_url,omitempty"`
+	OAuthToken        string `json:"oauth_token,omitempty"`
+	AppID             int64  `json:"app_id,omitempty"`
+	AppPrivateKey     string `json:"app_private_key,omitempty"`
+	AppInstallationID int64  `json:"app_installation_id,omitempty"`
+}
+
+func (c *githubHostConfig) host() string {
+	return c.Host
+}
+
+func (c *githubHostConfig) apiURLs() (string, string) {
+	apiURL, graphqlURL := c.APIURL, c.GraphQLURL
+	switch {
+	case c.Host == GITHUB_HOST:
+		if apiURL == "" {
+			apiURL = "https://api.github.com/"
+		}
+		if graphqlURL == "" {
+			graphqlURL = "https://api.github.com/graphql"
+		}
+	case strings.HasSuffix(c.Host, ".ghe.com"):
+		// https://docs.github.com/en/enterprise-cloud@latest/admin/data-residency/network-details-for-ghecom
+		if apiURL == "" {
+			apiURL = fmt.Sprintf("https://api.%s/", c.Host)
+		}
+		if graphqlURL == "" {
+			graphqlURL = fmt.Sprintf("https://api.%s/graphql", c.Host)
+		}
+	default:
+		if apiURL == "" {
+			apiURL = fmt.Sprintf("https://%s/api/v3/", c.Host)
+		}
+		if graphqlURL == "" {
+			graphqlURL = fmt.Sprintf("https://%s/api/graphql", c.Host)
+		}
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	return apiURL, graphqlURL
+}
+
+func newGithubProvider(c *githubHostConfig) (*githubProvider, error) {
+	apiURL, graphqlURL := c.apiURLs()
+
+	var hc *http.Client
+	switch {
+	case c.OAuthToken != "":
+		sts := oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: c.OAuthToken},
+		)
+		hc = oauth2.NewClient(context.Background(), sts)
+	case c.AppID != 0 && c.AppPrivateKey != "" && c.AppInstallationID != 0:
+		key, err := base64.StdEncoding.DecodeString(c.AppPrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode GitHub App private key: %w", err)
+		}
+		itr, err := ghinstallation.New(http.DefaultTransport, c.AppID, c.AppInstallationID, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GitHub client authenticated by GitHub App: %w", err)
+		}
+		itr.BaseURL = strings.TrimSuffix(apiURL, "/")
+		hc = &http.Client{Transport: itr}
+	default:
+		return nil, fmt.Errorf("missing an authentication config for %s", c.Host)
+	}
+
+	rest := github.NewClient(hc)
+	if c.Host != GITHUB_HOST {
+		var err error
+		// The uploads endpoint of GitHub Enterprise Server is placed next to the API one.
+		uploadURL := strings.TrimSuffix(apiURL, "api/v3/")
+		rest, err = rest.WithEnterpriseURLs(apiURL, uploadURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &githubProvider{
+		rest:    rest,
+		graphql: githubv4.NewEnterpriseClient(graphqlURL, hc),
+	}, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type gitlabHostConfig struct {
+	Host   string `json:"host"`
+	APIURL string `json:"api_url,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+func (c *gitlabHostConfig) host() string {
+	return c.Host
+}
+
+// Each comment is posted as a resolvable thread, so that the outdated one can be resolved to collapse it.
+type gitlabProvider struct {
+	*apiClient
+}
+
+func newGitlabProvider(c *gitlabHostConfig) (*gitlabProvider, error) {
+	if c.Token == "" {
+		return nil, fmt.Errorf("missing an authentication config for %s", c.Host)
+	}
+
+	apiURL := c.APIURL
+	if apiURL == "" {
+		apiURL = fmt.Sprintf("https://%s/api/v4/", c.Host)
+	}
+
+	if _, err := url.Parse(apiURL); err != nil {
+		return nil, err
+	}
+
+	return &gitlabProvider{newAPIClient(apiURL, bearerAuth(c.Token))}, nil
+}
+
+type gitlabNote struct {
+	ID       int    `json:"id"`
+	Body     string `json:"body"`
+	Resolved bool   `json:"resolved"`
+}
+
+type gitlabDiscussion struct {
+	ID    string        `json:"id"`
+	Notes []*gitlabNote `json:"notes"`
+}
+
+func (p *gitlabProvider) parseURL(u *url.URL) (gitURL, error) {
+	return newGitlabURL(u)
+}
+
+// https://docs.gitlab.com/ee/user/discussions/
+func (p *gitlabProvider) maxCommentLength() int {
+	return 1000000
+}
+
+// https://docs.gitlab.com/ee/api/discussions.html#list-project-merge-request-discussion-items
+func (p *gitlabProvider) listComments(ctx context.Context, u gitURL) ([]*vcsComment, error) {
+	var comments []*vcsComment
+	for page := "1"; page != ""; {
+		query := url.Values{
+			"per_page": {"100"},
+			"page":     {page},
+		}
+
+		var discussions []*gitlabDiscussion
+		resp, err := p.do(ctx, http.MethodGet, p.discussionsURL(u, query), nil, &discussions)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range discussions {
+			if len(d.Notes) == 0 {
+				continue
+			}
+			note := d.Notes[0]
+			comments = append(comments, &vcsComment{
+				ID:       strconv.Itoa(note.ID),
+				ThreadID: d.ID,
+				Body:     note.Body,
+				Hidden:   note.Resolved,
+			})
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return comments, nil
+}
+
+// https://docs.gitlab.com/ee/api/discussions.html#create-new-merge-request-thread
+func (p *gitlabProvider) createComment(ctx context.Context, u gitURL, comment *vcsNewComment) error {
+	in := map[string]string{"body": comment.Body}
+	_, err := p.do(ctx, http.MethodPost, p.discussionsURL(u, nil), in, nil)
+	return err
+}
+
+// https://docs.gitlab.com/ee/api/discussions.html#modify-an-existing-merge-request-thread-note
+func (p *gitlabProvider) updateComment(ctx context.Context, u gitURL, comment *vcsComment, update *vcsNewComment) error {
+	in := map[string]string{"body": update.Body}
+	_, err := p.do(ctx, http.MethodPut, p.discussionsURL(u, nil, comment.ThreadID, "notes", comment.ID), in, nil)
+	return err
+}
+
+// https://docs.gitlab.com/ee/api/discussions.html#resolve-a-merge-request-thread
+func (p *gitlabProvider) hideComment(ctx context.Context, u gitURL, comment *vcsComment) error {
+	query := url.Values{"resolved": {"true"}}
+	_, err := p.do(ctx, http.MethodPut, p.discussionsURL(u, query, comment.ThreadID), nil, nil)
+	return err
+}
+
+func (p *gitlabProvider) discussionsURL(u gitURL, query url.Values, paths ...string) string {
+	// The URL-encoded project path can be used as the project ID.
+	project := url.PathEscape(u.Owner() + "/" + u.Repository())
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s/projects/%s/merge_requests/%d/discussions", p.apiURL, project, u.PullRequest())
+	for _, path := range paths {
+		b.WriteString("/")
+		b.WriteString(url.PathEscape(path))
+	}
+	if len(query) > 0 {
+		b.WriteString("?")
+		b.WriteString(query.Encode())
+	}
+	return b.String()
+}
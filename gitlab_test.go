@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeGitlab is a stand-in of the GitLab API serving the discussions of a merge request.
+type fakeGitlab struct {
+	t           *testing.T
+	discussions []*gitlabDiscussion
+	perPage     int
+	nextID      int
+}
+
+const fakeGitlabDiscussionsPath = "/api/v4/projects/group%2Fsub%2Fproject/merge_requests/7/discussions"
+
+func (f *fakeGitlab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if got := r.Header.Get("Authorization"); got != "Bearer token" {
+		f.t.Errorf("Authorization = %q, want %q", got, "Bearer token")
+	}
+
+	// The project path must be URL-encoded as a single path segment.
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, fakeGitlabDiscussionsPath) {
+		f.t.Errorf("unexpected path: %s", path)
+		http.NotFound(w, r)
+		return
+	}
+	rest := strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, fakeGitlabDiscussionsPath), "/"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && rest[0] == "":
+		var page int
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		start, end := (page-1)*f.perPage, page*f.perPage
+		if end < len(f.discussions) {
+			w.Header().Set("X-Next-Page", fmt.Sprint(page+1))
+		} else {
+			end = len(f.discussions)
+		}
+		json.NewEncoder(w).Encode(f.discussions[start:end])
+	case r.Method == http.MethodPost && rest[0] == "":
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		f.nextID++
+		d := &gitlabDiscussion{ID: fmt.Sprintf("d%d", f.nextID), Notes: []*gitlabNote{{ID: f.nextID, Body: in["body"]}}}
+		f.discussions = append(f.discussions, d)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(d)
+	case r.Method == http.MethodPut && len(rest) == 3 && rest[1] == "notes":
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		note := f.note(rest[0], rest[2])
+		if note == nil {
+			http.NotFound(w, r)
+			return
+		}
+		note.Body = in["body"]
+		json.NewEncoder(w).Encode(note)
+	case r.Method == http.MethodPut && len(rest) == 1:
+		d := f.discussion(rest[0])
+		if d == nil {
+			http.NotFound(w, r)
+			return
+		}
+		for _, n := range d.Notes {
+			n.Resolved = r.URL.Query().Get("resolved") == "true"
+		}
+		json.NewEncoder(w).Encode(d)
+	default:
+		f.t.Errorf("unexpected request: %s %s", r.Method, path)
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeGitlab) discussion(id string) *gitlabDiscussion {
+	for _, d := range f.discussions {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func (f *fakeGitlab) note(discussionID, noteID string) *gitlabNote {
+	d := f.discussion(discussionID)
+	if d == nil {
+		return nil
+	}
+	for _, n := range d.Notes {
+		if fmt.Sprint(n.ID) == noteID {
+			return n
+		}
+	}
+	return nil
+}
+
+func newTestGitlabProvider(t *testing.T, f *fakeGitlab) *gitlabProvider {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	p, err := newGitlabProvider(&gitlabHostConfig{Host: "gitlab.example.com", APIURL: srv.URL + "/api/v4/", Token: "token"})
+	if err != nil {
+		t.Fatalf("failed to create the provider: %v", err)
+	}
+	return p
+}
+
+func testGitlabURL(t *testing.T) gitURL {
+	u, err := url.Parse("https://gitlab.example.com/group/sub/project/-/merge_requests/7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gu, err := newGitlabURL(u)
+	if err != nil {
+		t.Fatalf("failed to parse the URL: %v", err)
+	}
+	return gu
+}
+
+func TestGitlabProviderListCommentsPaginates(t *testing.T) {
+	f := &fakeGitlab{t: t, perPage: 2}
+	for i := 1; i <= 5; i++ {
+		f.discussions = append(f.discussions, &gitlabDiscussion{
+			ID:    fmt.Sprintf("d%d", i),
+			Notes: []*gitlabNote{{ID: i, Body: fmt.Sprintf("comment %d", i), Resolved: i == 2}},
+		})
+	}
+	// The discussion without notes is skipped.
+	f.discussions = append(f.discussions, &gitlabDiscussion{ID: "empty"})
+	p := newTestGitlabProvider(t, f)
+
+	comments, err := p.listComments(context.Background(), testGitlabURL(t))
+	if err != nil {
+		t.Fatalf("failed to list the comments: %v", err)
+	}
+	if len(comments) != 5 {
+		t.Fatalf("comments = %d, want 5", len(comments))
+	}
+	for i, c := range comments {
+		want := &vcsComment{ID: fmt.Sprint(i + 1), ThreadID: fmt.Sprintf("d%d", i+1), Body: fmt.Sprintf("comment %d", i+1), Hidden: i == 1}
+		if *c != *want {
+			t.Errorf("comments[%d] = %+v, want %+v", i, c, want)
+		}
+	}
+}
+
+func TestGitlabProviderComment(t *testing.T) {
+	f := &fakeGitlab{t: t, perPage: 100}
+	p := newTestGitlabProvider(t, f)
+	ctx, u := context.Background(), testGitlabURL(t)
+
+	if err := p.createComment(ctx, u, &vcsNewComment{Body: "first"}); err != nil {
+		t.Fatalf("failed to create the comment: %v", err)
+	}
+	comments, err := p.listComments(ctx, u)
+	if err != nil {
+		t.Fatalf("failed to list the comments: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Body != "first" {
+		t.Fatalf("comments = %+v, want the created one", comments)
+	}
+
+	if err := p.updateComment(ctx, u, comments[0], &vcsNewComment{Body: "edited"}); err != nil {
+		t.Fatalf("failed to update the comment: %v", err)
+	}
+	if got := f.discussions[0].Notes[0].Body; got != "edited" {
+		t.Errorf("body = %q, want %q", got, "edited")
+	}
+
+	if err := p.hideComment(ctx, u, comments[0]); err != nil {
+		t.Fatalf("failed to hide the comment: %v", err)
+	}
+	if !f.discussions[0].Notes[0].Resolved {
+		t.Error("the discussion is not resolved")
+	}
+
+	if err := p.updateComment(ctx, u, &vcsComment{ID: "99", ThreadID: "missing"}, &vcsNewComment{Body: "body"}); err == nil {
+		t.Error("updating the missing comment succeeded")
+	}
+}
@@ -8,7 +8,10 @@ import (
 )
 
 const (
-	GITHUB_HOST string = "github.com"
+	GITHUB_HOST    string = "github.com"
+	GITLAB_HOST    string = "gitlab.com"
+	BITBUCKET_HOST string = "bitbucket.org"
+	AZURE_HOST     string = "dev.azure.com"
 )
 
 type gitURL interface {
@@ -18,22 +21,6 @@ type gitURL interface {
 	PullRequest() int
 }
 
-func newGitURL(v string) (gitURL, error) {
-	url, err := url.Parse(v)
-	if err != nil {
-		return nil, err
-	}
-	host := url.Hostname()
-
-	switch host {
-	case GITHUB_HOST:
-		return newGithubURL(url), nil
-	default:
-		// TODO: Support GitHub Enterprise. Currently GitHub is only supported.
-		return nil, fmt.Errorf("unsupported host: %s", host)
-	}
-}
-
 type githubURL struct {
 	host        string
 	owner       string
@@ -41,19 +28,24 @@ type githubURL struct {
 	pullRequest int
 }
 
-func newGithubURL(url *url.URL) *githubURL {
-	var (
-		paths      = strings.Split(url.Path[1:], "/")
-		owner      = paths[0]
-		repository = paths[1]
-		number, _  = strconv.Atoi(paths[3])
-	)
+// https://<host>/<owner>/<repository>/pull/<number>
+func newGithubURL(url *url.URL) (*githubURL, error) {
+	paths := strings.Split(strings.Trim(url.Path, "/"), "/")
+	if len(paths) < 4 || paths[2] != "pull" {
+		return nil, fmt.Errorf("unsupported pull request URL: %s", url)
+	}
+
+	number, err := strconv.Atoi(paths[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid pull request number: %s", paths[3])
+	}
+
 	return &githubURL{
-		host:        GITHUB_HOST,
-		owner:       owner,
-		repository:  repository,
+		host:        url.Hostname(),
+		owner:       paths[0],
+		repository:  paths[1],
 		pullRequest: number,
-	}
+	}, nil
 }
 
 func (g *githubURL) Host() string {
@@ -71,3 +63,149 @@ func (g *githubURL) Repository() string {
 func (g *githubURL) PullRequest() int {
 	return g.pullRequest
 }
+
+type gitlabURL struct {
+	host         string
+	namespace    string
+	project      string
+	mergeRequest int
+}
+
+// https://<host>/<namespace>/<project>/-/merge_requests/<iid>, where the namespace can contain subgroups.
+func newGitlabURL(url *url.URL) (*gitlabURL, error) {
+	projectPath, rest, _ := strings.Cut(strings.Trim(url.Path, "/"), "/-/merge_requests/")
+	i := strings.LastIndex(projectPath, "/")
+	if i <= 0 {
+		return nil, fmt.Errorf("unsupported merge request URL: %s", url)
+	}
+
+	iid, _, _ := strings.Cut(rest, "/")
+	number, err := strconv.Atoi(iid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merge request number: %s", iid)
+	}
+
+	return &gitlabURL{
+		host:         url.Hostname(),
+		namespace:    projectPath[:i],
+		project:      projectPath[i+1:],
+		mergeRequest: number,
+	}, nil
+}
+
+func (g *gitlabURL) Host() string {
+	return g.host
+}
+
+func (g *gitlabURL) Owner() string {
+	return g.namespace
+}
+
+func (g *gitlabURL) Repository() string {
+	return g.project
+}
+
+func (g *gitlabURL) PullRequest() int {
+	return g.mergeRequest
+}
+
+type bitbucketURL struct {
+	host        string
+	owner       string
+	repository  string
+	pullRequest int
+}
+
+// https://<host>/<workspace>/<repository>/pull-requests/<id> for Bitbucket Cloud
+// https://<host>/projects/<key>/repos/<slug>/pull-requests/<id> for Bitbucket Data Center
+func newBitbucketURL(url *url.URL) (*bitbucketURL, error) {
+	paths := strings.Split(strings.Trim(url.Path, "/"), "/")
+
+	// Bitbucket Data Center can be served under a context path.
+	for i := 0; i+5 < len(paths); i++ {
+		if paths[i] == "projects" && paths[i+2] == "repos" && paths[i+4] == "pull-requests" {
+			return newBitbucketURLFromPaths(url, paths[i+1], paths[i+3], paths[i+5])
+		}
+	}
+
+	if len(paths) < 4 || paths[2] != "pull-requests" {
+		return nil, fmt.Errorf("unsupported pull request URL: %s", url)
+	}
+	return newBitbucketURLFromPaths(url, paths[0], paths[1], paths[3])
+}
+
+func newBitbucketURLFromPaths(url *url.URL, owner, repository, id string) (*bitbucketURL, error) {
+	number, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pull request number: %s", id)
+	}
+
+	return &bitbucketURL{
+		host:        url.Hostname(),
+		owner:       owner,
+		repository:  repository,
+		pullRequest: number,
+	}, nil
+}
+
+func (b *bitbucketURL) Host() string {
+	return b.host
+}
+
+func (b *bitbucketURL) Owner() string {
+	return b.owner
+}
+
+func (b *bitbucketURL) Repository() string {
+	return b.repository
+}
+
+func (b *bitbucketURL) PullRequest() int {
+	return b.pullRequest
+}
+
+type azureURL struct {
+	host        string
+	project     string
+	repository  string
+	pullRequest int
+}
+
+// https://<host>/<organization>/<project>/_git/<repository>/pullrequest/<id>
+// The owner is the path before the repository, which is "<project>" for <organization>.visualstudio.com
+// and "<collection>/<project>" for Azure DevOps Server.
+func newAzureURL(url *url.URL) (*azureURL, error) {
+	project, rest, _ := strings.Cut(strings.Trim(url.Path, "/"), "/_git/")
+	paths := strings.Split(rest, "/")
+	if project == "" || len(paths) < 3 || !strings.EqualFold(paths[1], "pullrequest") {
+		return nil, fmt.Errorf("unsupported pull request URL: %s", url)
+	}
+
+	number, err := strconv.Atoi(paths[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid pull request number: %s", paths[2])
+	}
+
+	return &azureURL{
+		host:        url.Hostname(),
+		project:     project,
+		repository:  paths[0],
+		pullRequest: number,
+	}, nil
+}
+
+func (a *azureURL) Host() string {
+	return a.host
+}
+
+func (a *azureURL) Owner() string {
+	return a.project
+}
+
+func (a *azureURL) Repository() string {
+	return a.repository
+}
+
+func (a *azureURL) PullRequest() int {
+	return a.pullRequest
+}
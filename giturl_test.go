@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+type gitURLWant struct {
+	host        string
+	owner       string
+	repository  string
+	pullRequest int
+}
+
+func assertGitURL(t *testing.T, raw string, got gitURL, err error, want *gitURLWant) {
+	t.Helper()
+	if want == nil {
+		if err == nil {
+			t.Errorf("%s: want an error, got %+v", raw, got)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("%s: unexpected error: %v", raw, err)
+		return
+	}
+	if got.Host() != want.host || got.Owner() != want.owner || got.Repository() != want.repository || got.PullRequest() != want.pullRequest {
+		t.Errorf("%s: got (%s, %s, %s, %d), want (%s, %s, %s, %d)", raw,
+			got.Host(), got.Owner(), got.Repository(), got.PullRequest(),
+			want.host, want.owner, want.repository, want.pullRequest)
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestNewGitlabURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want *gitURLWant
+	}{
+		{url: "https://gitlab.com/group/project/-/merge_requests/1", want: &gitURLWant{"gitlab.com", "group", "project", 1}},
+		{url: "https://gitlab.com/group/sub/project/-/merge_requests/12/diffs", want: &gitURLWant{"gitlab.com", "group/sub", "project", 12}},
+		{url: "https://gitlab.example.com:8443/a/b/c/d/-/merge_requests/3", want: &gitURLWant{"gitlab.example.com", "a/b/c", "d", 3}},
+		{url: "https://gitlab.com/project/-/merge_requests/1"},
+		{url: "https://gitlab.com/group/project/-/merge_requests/abc"},
+		{url: "https://gitlab.com/group/project/-/issues/1"},
+	}
+	for _, tt := range tests {
+		got, err := newGitlabURL(mustParseURL(t, tt.url))
+		assertGitURL(t, tt.url, got, err, tt.want)
+	}
+}
+
+func TestNewBitbucketURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want *gitURLWant
+	}{
+		{url: "https://bitbucket.org/workspace/repo/pull-requests/5", want: &gitURLWant{"bitbucket.org", "workspace", "repo", 5}},
+		{url: "https://bitbucket.org/workspace/repo/pull-requests/5/overview", want: &gitURLWant{"bitbucket.org", "workspace", "repo", 5}},
+		{url: "https://bitbucket.example.com/projects/KEY/repos/slug/pull-requests/8/overview", want: &gitURLWant{"bitbucket.example.com", "KEY", "slug", 8}},
+		{url: "https://example.com/bitbucket/projects/KEY/repos/slug/pull-requests/9", want: &gitURLWant{"example.com", "KEY", "slug", 9}},
+		{url: "https://bitbucket.org/workspace/repo/pull-requests/abc"},
+		{url: "https://bitbucket.org/workspace/repo/commits/1"},
+	}
+	for _, tt := range tests {
+		got, err := newBitbucketURL(mustParseURL(t, tt.url))
+		assertGitURL(t, tt.url, got, err, tt.want)
+	}
+}
+
+func TestNewAzureURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want *gitURLWant
+	}{
+		{url: "https://dev.azure.com/org/project/_git/repo/pullrequest/10", want: &gitURLWant{"dev.azure.com", "org/project", "repo", 10}},
+		{url: "https://org.visualstudio.com/project/_git/repo/pullRequest/11", want: &gitURLWant{"org.visualstudio.com", "project", "repo", 11}},
+		{url: "https://azure.example.com/tfs/collection/project/_git/repo/pullrequest/12", want: &gitURLWant{"azure.example.com", "tfs/collection/project", "repo", 12}},
+		{url: "https://dev.azure.com/_git/repo/pullrequest/1"},
+		{url: "https://dev.azure.com/org/project/_git/repo/pullrequest/abc"},
+		{url: "https://dev.azure.com/org/project/_git/repo"},
+	}
+	for _, tt := range tests {
+		got, err := newAzureURL(mustParseURL(t, tt.url))
+		assertGitURL(t, tt.url, got, err, tt.want)
+	}
+}
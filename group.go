@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const (
+	commentGroupByNone   = "none"
+	commentGroupByModule = "module"
+	commentGroupByAction = "action"
+)
+
+type changeGroup struct {
+	heading string
+	changes []*tfjson.ResourceChange
+}
+
+// Without grouping, all the changes are put into a group without a heading.
+func groupResourceChanges(changes []*tfjson.ResourceChange, groupBy string) []*changeGroup {
+	switch groupBy {
+	case commentGroupByModule:
+		return groupChangesByModule(changes)
+	case commentGroupByAction:
+		return groupChangesByAction(changes)
+	default:
+		return []*changeGroup{{changes: changes}}
+	}
+}
+
+type moduleNode struct {
+	address  string
+	changes  []*tfjson.ResourceChange
+	children []*moduleNode
+}
+
+func (n *moduleNode) allChanges() []*tfjson.ResourceChange {
+	changes := append([]*tfjson.ResourceChange(nil), n.changes...)
+	for _, c := range n.children {
+		changes = append(changes, c.allChanges()...)
+	}
+	return changes
+}
+
+// The modules without their own changes are still listed to keep the tree.
+// The root module only counts its own resources, since the whole plan is summarized above.
+func groupChangesByModule(changes []*tfjson.ResourceChange) []*changeGroup {
+	root := &moduleNode{}
+	nodes := map[string]*moduleNode{"": root}
+	var node func(addr string) *moduleNode
+	node = func(addr string) *moduleNode {
+		if n, ok := nodes[addr]; ok {
+			return n
+		}
+		n := &moduleNode{address: addr}
+		nodes[addr] = n
+		parent := node(parentModuleAddress(addr))
+		parent.children = append(parent.children, n)
+		return n
+	}
+	for _, c := range changes {
+		n := node(c.ModuleAddress)
+		n.changes = append(n.changes, c)
+	}
+
+	var groups []*changeGroup
+	if len(root.changes) > 0 {
+		groups = append(groups, &changeGroup{
+			heading: fmt.Sprintf("#### Root module\n`%s`", summarizeResourceChanges(root.changes)),
+			changes: root.changes,
+		})
+	}
+
+	// A heading is only written with the first change of its group, so that the empty modules join the next group.
+	var headings []string
+	var walk func(n *moduleNode, depth int)
+	walk = func(n *moduleNode, depth int) {
+		headings = append(headings, fmt.Sprintf("%s `%s`\n`%s`", strings.Repeat("#", min(4+depth, 6)), n.address, summarizeResourceChanges(n.allChanges())))
+		if len(n.changes) > 0 {
+			groups = append(groups, &changeGroup{
+				heading: strings.Join(headings, "\n\n"),
+				changes: n.changes,
+			})
+			headings = nil
+		}
+
+		sort.Slice(n.children, func(i, j int) bool {
+			return n.children[i].address < n.children[j].address
+		})
+		for _, c := range n.children {
+			walk(c, depth+1)
+		}
+	}
+	sort.Slice(root.children, func(i, j int) bool {
+		return root.children[i].address < root.children[j].address
+	})
+	for _, c := range root.children {
+		walk(c, 0)
+	}
+	return groups
+}
+
+// The instance keys are skipped, since they can contain any string, e.g. module.a["x.module.b"].
+func parentModuleAddress(addr string) string {
+	var (
+		parent  int
+		inKey   bool
+		inQuote bool
+	)
+	for i := 0; i < len(addr); i++ {
+		switch ch := addr[i]; {
+		case inQuote:
+			if ch == '\\' {
+				i++
+			} else if ch == '"' {
+				inQuote = false
+			}
+		case ch == '"' && inKey:
+			inQuote = true
+		case ch == '[':
+			inKey = true
+		case ch == ']':
+			inKey = false
+		case !inKey && ch == '.' && strings.HasPrefix(addr[i:], ".module."):
+			parent = i
+		}
+	}
+	return addr[:parent]
+}
+
+func groupChangesByAction(changes []*tfjson.ResourceChange) []*changeGroup {
+	actions := make(map[Action][]*tfjson.ResourceChange, len(actionOrder))
+	for _, c := range changes {
+		action := UnmarshalActions(c.Change.Actions)
+		actions[action] = append(actions[action], c)
+	}
+
+	var groups []*changeGroup
+	for _, action := range actionOrder {
+		cs := actions[action]
+		if len(cs) == 0 {
+			continue
+		}
+		groups = append(groups, &changeGroup{
+			heading: fmt.Sprintf("#### %s %d %s %s", action.Symbol(), len(cs), plural(len(cs), "resource"), action.Description()),
+			changes: cs,
+		})
+	}
+	return groups
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+func TestParentModuleAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{addr: "module.a", want: ""},
+		{addr: "module.a.module.b", want: "module.a"},
+		{addr: "module.a[0].module.b[\"x\"].module.c", want: "module.a[0].module.b[\"x\"]"},
+		{addr: "module.a[\"x.module.y\"]", want: ""},
+		{addr: "module.a[\"x\\\"].module.y\"].module.b", want: "module.a[\"x\\\"].module.y\"]"},
+	}
+	for _, tt := range tests {
+		if got := parentModuleAddress(tt.addr); got != tt.want {
+			t.Errorf("parentModuleAddress(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestGroupChangesByModule(t *testing.T) {
+	change := func(module, name string, actions ...tfjson.Action) *tfjson.ResourceChange {
+		address := "null_resource." + name
+		if module != "" {
+			address = module + "." + address
+		}
+		return &tfjson.ResourceChange{
+			Address:       address,
+			ModuleAddress: module,
+			Change:        &tfjson.Change{Actions: actions},
+		}
+	}
+
+	groups := groupChangesByModule([]*tfjson.ResourceChange{
+		change("module.a.module.b", "x", tfjson.ActionCreate),
+		change("", "root", tfjson.ActionUpdate),
+		change("module.a", "y", tfjson.ActionDelete),
+		change("module.c.module.d", "z", tfjson.ActionCreate),
+		change("module.a.module.b.module.e", "w", tfjson.ActionCreate),
+	})
+
+	want := []struct {
+		heading string
+		changes []string
+	}{
+		{
+			heading: "#### Root module\n`+ 0 to add, ~ 1 to change, - 0 to destroy.`",
+			changes: []string{"null_resource.root"},
+		},
+		{
+			heading: "#### `module.a`\n`+ 2 to add, ~ 0 to change, - 1 to destroy.`",
+			changes: []string{"module.a.null_resource.y"},
+		},
+		{
+			heading: "##### `module.a.module.b`\n`+ 2 to add, ~ 0 to change, - 0 to destroy.`",
+			changes: []string{"module.a.module.b.null_resource.x"},
+		},
+		{
+			heading: "###### `module.a.module.b.module.e`\n`+ 1 to add, ~ 0 to change, - 0 to destroy.`",
+			changes: []string{"module.a.module.b.module.e.null_resource.w"},
+		},
+		{
+			// The parent without its own changes is listed with its child.
+			heading: "#### `module.c`\n`+ 1 to add, ~ 0 to change, - 0 to destroy.`\n\n" +
+				"##### `module.c.module.d`\n`+ 1 to add, ~ 0 to change, - 0 to destroy.`",
+			changes: []string{"module.c.module.d.null_resource.z"},
+		},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("groups = %d, want %d", len(groups), len(want))
+	}
+	for i, g := range groups {
+		if g.heading != want[i].heading {
+			t.Errorf("groups[%d].heading = %q, want %q", i, g.heading, want[i].heading)
+		}
+		var addresses []string
+		for _, c := range g.changes {
+			addresses = append(addresses, c.Address)
+		}
+		if strings.Join(addresses, ",") != strings.Join(want[i].changes, ",") {
+			t.Errorf("groups[%d].changes = %v, want %v", i, addresses, want[i].changes)
+		}
+	}
+}
+
+func TestMakeIssueCommentGroupByActionMergesDeposed(t *testing.T) {
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			{
+				Address: "null_resource.created", Mode: tfjson.ManagedResourceMode, Type: "null_resource", Name: "created",
+				Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionCreate}, After: map[string]interface{}{}},
+			},
+			{
+				Address: "null_resource.deposed", Mode: tfjson.ManagedResourceMode, Type: "null_resource", Name: "deposed", DeposedKey: "abcd1234",
+				Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete}, Before: map[string]interface{}{}},
+			},
+			{
+				Address: "null_resource.deleted", Mode: tfjson.ManagedResourceMode, Type: "null_resource", Name: "deleted",
+				Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete}, Before: map[string]interface{}{}},
+			},
+		},
+	}
+	req := &TFERunTasksRequest{RunID: "run-1", WorkspaceID: "ws-1"}
+
+	comments, err := makeIssueComment(plan, req, nil, &commentOptions{groupBy: commentGroupByAction, maxLength: testMaxCommentLength})
+	if err != nil {
+		t.Fatalf("failed to make the comment: %v", err)
+	}
+	body := comments[0]
+
+	if strings.Contains(body, "#### Deposed objects") {
+		t.Error("the deposed object is listed in its own group")
+	}
+	deleteHeading := strings.Index(body, "#### - 2 resources will be destroyed")
+	if deleteHeading < 0 {
+		t.Fatalf("missing the delete group in:\n%s", body)
+	}
+	// The addresses are also listed in the metadata, so that the changes are looked up after the heading.
+	group := body[deleteHeading:]
+	created := strings.Index(group, "# null_resource.created will be created")
+	for _, addr := range []string{"# null_resource.deposed (deposed object abcd1234)", "# null_resource.deleted will be destroyed"} {
+		if i := strings.Index(group, addr); i < 0 || i > created {
+			t.Errorf("%q is not listed in the delete group", addr)
+		}
+	}
+}
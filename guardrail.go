@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type guardrailLevel string
+
+const (
+	guardrailWarn guardrailLevel = "warn"
+	guardrailFail guardrailLevel = "fail"
+)
+
+const (
+	enforcementAdvisory  = "advisory"
+	enforcementMandatory = "mandatory"
+)
+
+// guardrail is a threshold over the number of changes, e.g. "destroy>5:fail".
+type guardrail struct {
+	metric    string
+	inclusive bool
+	threshold int
+	level     guardrailLevel
+}
+
+var guardrailMetrics = map[string]func(cs *ChangeSummary) int{
+	"add":     func(cs *ChangeSummary) int { return cs.Add },
+	"change":  func(cs *ChangeSummary) int { return cs.Change },
+	"destroy": func(cs *ChangeSummary) int { return cs.Remove },
+	"replace": func(cs *ChangeSummary) int { return cs.Replace },
+	"import":  func(cs *ChangeSummary) int { return cs.Import },
+	"move":    func(cs *ChangeSummary) int { return cs.Move },
+}
+
+// parseGuardrails parses the comma separated rules formatted as "<metric><op><threshold>:<level>".
+func parseGuardrails(v string) ([]*guardrail, error) {
+	var rules []*guardrail
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		expr, level, ok := strings.Cut(s, ":")
+		if !ok {
+			return nil, fmt.Errorf("missing level in guardrail: %s", s)
+		}
+
+		g := &guardrail{level: guardrailLevel(level)}
+		if g.level != guardrailWarn && g.level != guardrailFail {
+			return nil, fmt.Errorf("invalid level in guardrail: %s", s)
+		}
+
+		metric, threshold, ok := strings.Cut(expr, ">")
+		if !ok {
+			return nil, fmt.Errorf("missing operator in guardrail: %s", s)
+		}
+		if strings.HasPrefix(threshold, "=") {
+			g.inclusive = true
+			threshold = threshold[1:]
+		}
+
+		g.metric = strings.TrimSpace(metric)
+		if _, ok := guardrailMetrics[g.metric]; !ok {
+			return nil, fmt.Errorf("unsupported metric in guardrail: %s", s)
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(threshold))
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold in guardrail: %s", s)
+		}
+		g.threshold = n
+
+		rules = append(rules, g)
+	}
+	return rules, nil
+}
+
+func (g *guardrail) String() string {
+	op := ">"
+	if g.inclusive {
+		op = ">="
+	}
+	return fmt.Sprintf("%s%s%d:%s", g.metric, op, g.threshold, g.level)
+}
+
+type guardrailViolation struct {
+	rule   *guardrail
+	actual int
+}
+
+func (v *guardrailViolation) String() string {
+	op := "more than"
+	if v.rule.inclusive {
+		op = "at least"
+	}
+	return fmt.Sprintf("%d to %s, which is %s %d (%s)", v.actual, v.rule.metric, op, v.rule.threshold, v.rule)
+}
+
+type guardrailReport struct {
+	violations []*guardrailViolation
+	mandatory  bool
+}
+
+func evaluateGuardrails(rules []*guardrail, cs *ChangeSummary, enforcementLevel string) *guardrailReport {
+	r := &guardrailReport{
+		mandatory: enforcementLevel == enforcementMandatory,
+	}
+	for _, g := range rules {
+		actual := guardrailMetrics[g.metric](cs)
+		if actual > g.threshold || (g.inclusive && actual == g.threshold) {
+			r.violations = append(r.violations, &guardrailViolation{rule: g, actual: actual})
+		}
+	}
+	return r
+}
+
+func (r *guardrailReport) failed() bool {
+	for _, v := range r.violations {
+		if v.rule.level == guardrailFail {
+			return true
+		}
+	}
+	return false
+}
+
+// A mandatory run task blocks the run, so it is reported more explicitly than an advisory one.
+func (r *guardrailReport) message() string {
+	if len(r.violations) == 0 {
+		return ""
+	}
+
+	rules := make([]string, 0, len(r.violations))
+	for _, v := range r.violations {
+		rules = append(rules, v.rule.String())
+	}
+
+	switch {
+	case r.failed() && r.mandatory:
+		return fmt.Sprintf("Blocked by guardrails: %s. This run cannot be applied", strings.Join(rules, ", "))
+	case r.failed():
+		return fmt.Sprintf("Guardrails failed (advisory): %s", strings.Join(rules, ", "))
+	default:
+		return fmt.Sprintf("Guardrails warned: %s", strings.Join(rules, ", "))
+	}
+}
+
+func (r *guardrailReport) outcome(runURL string) *TFERunTasksResponseOutcome {
+	var b strings.Builder
+	for _, v := range r.violations {
+		fmt.Fprintf(&b, "- **%s**: %s\n", v.rule.level, v)
+	}
+
+	level := WARNING
+	if r.failed() && r.mandatory {
+		level = ERROR
+	}
+	label := "Warned"
+	if r.failed() {
+		label = "Failed"
+	}
+
+	return &TFERunTasksResponseOutcome{
+		Type: "task-result-outcomes",
+		Attributes: &TFERunTasksResponseOutcomesData{
+			OutcomeID:   "guardrails",
+			Description: r.message(),
+			Body:        b.String(),
+			URL:         runURL,
+			Tags: map[string][]*TFERunTasksResponseOutcomesTags{
+				"Status": {{Label: label, Level: level}},
+			},
+		},
+	}
+}
+
+func (r *guardrailReport) markdown() string {
+	if len(r.violations) == 0 {
+		return ""
+	}
+
+	kind := "WARNING"
+	if r.failed() && r.mandatory {
+		kind = "CAUTION"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "> [!%s]\n> %s\n>\n", kind, r.message())
+	for _, v := range r.violations {
+		fmt.Fprintf(&b, "> - **%s**: %s\n", v.rule.level, v)
+	}
+	return b.String()
+}
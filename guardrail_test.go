@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGuardrails(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", value: ""},
+		{name: "rules", value: "destroy>5:fail, add>=10:warn,", want: []string{"destroy>5:fail", "add>=10:warn"}},
+		{name: "spaces around the operator", value: " replace > 0:warn ", want: []string{"replace>0:warn"}},
+		{name: "missing level", value: "destroy>5", wantErr: true},
+		{name: "invalid level", value: "destroy>5:error", wantErr: true},
+		{name: "missing operator", value: "destroy=5:fail", wantErr: true},
+		{name: "unsupported metric", value: "remove>5:fail", wantErr: true},
+		{name: "invalid threshold", value: "destroy>five:fail", wantErr: true},
+		{name: "one malformed rule", value: "destroy>5:fail,add:warn", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseGuardrails(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGuardrails() = %v, want an error: %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, g := range rules {
+				got = append(got, g.String())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateGuardrails(t *testing.T) {
+	rules, err := parseGuardrails("destroy>5:fail,add>=10:warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		cs               *ChangeSummary
+		enforcementLevel string
+		violations       int
+		failed           bool
+		message          string
+		alert            string
+	}{
+		{name: "below the thresholds", cs: &ChangeSummary{Add: 9, Remove: 5}, enforcementLevel: enforcementMandatory},
+		{
+			name:             "inclusive threshold",
+			cs:               &ChangeSummary{Add: 10, Remove: 5},
+			enforcementLevel: enforcementMandatory,
+			violations:       1,
+			message:          "Guardrails warned: add>=10:warn",
+			alert:            "> [!WARNING]",
+		},
+		{
+			name:             "advisory failure",
+			cs:               &ChangeSummary{Add: 10, Remove: 6},
+			enforcementLevel: enforcementAdvisory,
+			violations:       2,
+			failed:           true,
+			message:          "Guardrails failed (advisory): destroy>5:fail, add>=10:warn",
+			alert:            "> [!WARNING]",
+		},
+		{
+			name:             "mandatory failure",
+			cs:               &ChangeSummary{Remove: 6},
+			enforcementLevel: enforcementMandatory,
+			violations:       1,
+			failed:           true,
+			message:          "Blocked by guardrails: destroy>5:fail. This run cannot be applied",
+			alert:            "> [!CAUTION]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := evaluateGuardrails(rules, tt.cs, tt.enforcementLevel)
+			if len(r.violations) != tt.violations {
+				t.Errorf("violations = %d, want %d", len(r.violations), tt.violations)
+			}
+			if r.failed() != tt.failed {
+				t.Errorf("failed = %v, want %v", r.failed(), tt.failed)
+			}
+			if got := r.message(); got != tt.message {
+				t.Errorf("message = %q, want %q", got, tt.message)
+			}
+			if got := r.markdown(); !strings.HasPrefix(got, tt.alert) || (tt.alert == "") != (got == "") {
+				t.Errorf("markdown = %q, want the %q alert", got, tt.alert)
+			}
+		})
+	}
+}
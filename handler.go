@@ -12,25 +12,68 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
-	"github.com/google/go-github/v56/github"
-	"github.com/shurcooL/githubv4"
+	tfjson "github.com/hashicorp/terraform-json"
 )
 
 type handler struct {
-	ghClient        *github.Client
-	ghGraphQLClient *githubv4.Client
-	httpClient      *http.Client
+	// providers are keyed by the host of the pull request URL.
+	providers  map[string]vcsProvider
+	httpClient *http.Client
+	pool       *workerPool
+	config     *handlerConfig
 }
 
-func newHandler(ghClient *github.Client, ghGraphQLClient *githubv4.Client) *handler {
+type handlerConfig struct {
+	taskTimeout     time.Duration
+	outcomesGroupBy string
+	commentMode     string
+	commentGroupBy  string
+	guardrails      []*guardrail
+	// viewer is nil when the plan viewer is disabled.
+	viewer *planViewer
+	// commentTemplate is nil to use the default layout.
+	commentTemplate *template.Template
+}
+
+func newHandler(providers map[string]vcsProvider, pool *workerPool, config *handlerConfig) *handler {
 	return &handler{
-		ghClient:        ghClient,
-		ghGraphQLClient: ghGraphQLClient,
-		httpClient:      &http.Client{Timeout: 10 * time.Second},
+		providers:  providers,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		pool:       pool,
+		config:     config,
+	}
+}
+
+// pullRequest parses the pull request URL by the provider of its host, since a self-hosted VCS can be served on any host.
+func (h *handler) pullRequest(v string) (vcsProvider, gitURL, error) {
+	u, err := url.Parse(v)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse VCS pull request URL: %w", err)
+	}
+
+	p, ok := h.providers[u.Hostname()]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported host: %s", u.Hostname())
 	}
+
+	pr, err := p.parseURL(u)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse VCS pull request URL: %w", err)
+	}
+	return p, pr, nil
+}
+
+type taskResult struct {
+	status   string
+	message  string
+	outcomes []*TFERunTasksResponseOutcome
 }
 
 func (h *handler) handleRunTask(w http.ResponseWriter, r *http.Request) {
@@ -70,84 +113,355 @@ func (h *handler) handleRunTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := context.Background()
+	if req.AccessToken == "" || req.TaskResultCallbackURL == "" {
+		log.Printf("Missing access token or callback url: %s", req.RunID)
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// TFC/E expects the response immediately and waits for the callback up to its own deadline,
+	// so the actual work is processed in the background.
+	j := &job{
+		name:     req.RunID,
+		deadline: time.Now().Add(h.config.taskTimeout),
+		run: func(ctx context.Context) error {
+			return h.processRunTask(ctx, req)
+		},
+		recovered: func(ctx context.Context) {
+			result := &taskResult{
+				status:  "failed",
+				message: "Failed pushing the plan result to VCS due to an internal error",
+			}
+			if err := h.sendCallback(ctx, req.TaskResultCallbackURL, req.AccessToken, result); err != nil {
+				log.Printf("Failed to send callback to TFC: %v", err)
+			}
+		},
+	}
+	if err := h.pool.submit(j); err != nil {
+		log.Printf("Unable to accept the run task: %v", err)
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+}
+
+func (h *handler) processRunTask(ctx context.Context, req *TFERunTasksRequest) error {
+	result, err := h.pushResult(ctx, req)
+	if err != nil {
+		log.Printf("Failed to push the plan result of %s: %v", req.RunID, err)
+		result = &taskResult{
+			status:  "failed",
+			message: "Failed pushing the plan result to VCS",
+		}
+	}
+
+	if err := h.sendCallback(ctx, req.TaskResultCallbackURL, req.AccessToken, result); err != nil {
+		return fmt.Errorf("failed to send callback to TFC: %w", err)
+	}
+	return nil
+}
+
+// https://developer.hashicorp.com/terraform/cloud-docs/integrations/run-tasks#run-task-stages-and-timeouts
+const (
+	stagePrePlan   = "pre_plan"
+	stagePostPlan  = "post_plan"
+	stagePostApply = "post_apply"
+)
+
+func (h *handler) pushResult(ctx context.Context, req *TFERunTasksRequest) (*taskResult, error) {
 	if req.VCSPullRequestURL == "" {
 		log.Printf("Skip this run because this might not be the event based on PR: %s", req.RunID)
+		return &taskResult{
+			status:  "passed",
+			message: "Skipped pushing the plan result to VCS",
+		}, nil
+	}
+
+	switch req.Stage {
+	case stagePrePlan:
+		return h.pushConfigurationSummary(ctx, req)
+	case stagePostPlan, "":
+		return h.pushPlanResult(ctx, req)
+	case stagePostApply:
+		return h.pushApplyResult(ctx, req)
+	default:
+		log.Printf("Skip this run because the stage is not supported: %s", req.Stage)
+		return &taskResult{
+			status:  "passed",
+			message: fmt.Sprintf("Skipped because the %s stage is not supported", req.Stage),
+		}, nil
+	}
+}
+
+func (h *handler) pushConfigurationSummary(ctx context.Context, req *TFERunTasksRequest) (*taskResult, error) {
+	p, url, err := h.pullRequest(req.VCSPullRequestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := downloadConfiguration(ctx, h.httpClient, req.ConfigurationVersionDownloadURL, req.AccessToken, req.WorkspaceWorkingDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download the configuration: %w", err)
+	}
+
+	summary, err := inspectConfiguration(files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect the configuration: %w", err)
+	}
 
-		msg := "Skipped pushing the plan result to VCS"
-		if err := h.sendCallback(ctx, req.TaskResultCallbackURL, req.AccessToken, msg); err != nil {
-			log.Printf("Failed to send callback to TFC: %v", err)
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-			return
+	// The summary is posted apart from the plan comments, so that it never edits nor hides them.
+	previous, err := findConfigurationComments(ctx, p, url, req.WorkspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query the previous configuration summary to minimize: %w", err)
+	}
+	if err := p.createComment(ctx, url, &vcsNewComment{Body: makeConfigurationComment(summary, req)}); err != nil {
+		return nil, fmt.Errorf("failed to create an issue comment: %w", err)
+	}
+	hideComments(ctx, p, url, previous)
+
+	return &taskResult{
+		status:  "passed",
+		message: "Succeeded pushing the configuration summary to VCS",
+	}, nil
+}
+
+func (h *handler) pushPlanResult(ctx context.Context, req *TFERunTasksRequest) (*taskResult, error) {
+	p, url, err := h.pullRequest(req.VCSPullRequestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, state, err := parsePlan(ctx, h.httpClient, req.PlanJSONAPIURL, req.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the plan: %w", err)
+	}
+
+	cs := summarizeChanges(plan)
+	guardrails := evaluateGuardrails(h.config.guardrails, cs, req.TaskResultEnforcementLevel)
+	createdAt := time.Now()
+	var viewerURL string
+	if h.config.viewer != nil {
+		viewerURL = h.config.viewer.link(req.RunID, createdAt)
+	}
+
+	bodies, err := h.makePlanComments(plan, state, req, guardrails, viewerURL, p.maxCommentLength())
+	if err != nil {
+		return nil, err
+	}
+	// The plan is only stored when the comment links to it, e.g. the changes are too long for a comment.
+	if viewerURL != "" && linksTo(bodies, viewerURL) {
+		if err := h.config.viewer.save(makePlanView(plan, state, req, createdAt)); err != nil {
+			log.Printf("Failed to save the plan to the plan viewer: %v", err)
+			// The comment is made again without the broken link.
+			if bodies, err = h.makePlanComments(plan, state, req, guardrails, "", p.maxCommentLength()); err != nil {
+				return nil, err
+			}
 		}
-		return
 	}
 
-	plan, err := parsePlan(ctx, h.httpClient, req.PlanJSONAPIURL, req.AccessToken)
+	comments := make([]*vcsNewComment, 0, len(bodies))
+	for _, body := range bodies {
+		comments = append(comments, &vcsNewComment{
+			Body:        body,
+			Destructive: cs.Remove > 0,
+		})
+	}
+	if err := h.postComments(ctx, p, url, req, comments); err != nil {
+		return nil, err
+	}
+	// The configuration summary is outdated once the plan is posted.
+	summaries, err := findConfigurationComments(ctx, p, url, req.WorkspaceID)
 	if err != nil {
-		log.Printf("Failed to get the plan: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		log.Printf("Failed to query the configuration summary to minimize: %v", err)
+	}
+	hideComments(ctx, p, url, summaries)
+
+	result := &taskResult{
+		status:  "passed",
+		message: "Succeeded pushing the plan result to VCS",
+	}
+	if len(guardrails.violations) > 0 {
+		result.message = guardrails.message()
+	}
+	if guardrails.failed() {
+		result.status = "failed"
+	}
+	// The errored plan is reported ahead of the guardrails, since its changes are partial.
+	if problem := state.problem(cs); problem != "" {
+		if len(guardrails.violations) > 0 {
+			problem += ". " + guardrails.message()
+		}
+		result.message = problem
+	}
+	if state.failed(cs) {
+		result.status = "failed"
+	}
+	if req.Capabilities != nil && req.Capabilities.Outcomes {
+		result.outcomes = makeOutcomes(plan, req.RunAppURL, h.config.outcomesGroupBy)
+		if outcome := checkOutcome(plan.Checks, req.RunAppURL); outcome != nil {
+			result.outcomes = append([]*TFERunTasksResponseOutcome{outcome}, result.outcomes...)
+		}
+		if len(guardrails.violations) > 0 {
+			result.outcomes = append([]*TFERunTasksResponseOutcome{guardrails.outcome(req.RunAppURL)}, result.outcomes...)
+		}
+	}
+	return result, nil
+}
+
+func (h *handler) makePlanComments(plan *tfjson.Plan, state *planState, req *TFERunTasksRequest, guardrails *guardrailReport, viewerURL string, maxLength int) ([]string, error) {
+	if h.config.commentTemplate != nil {
+		body, err := makeTemplatedComment(h.config.commentTemplate, plan, makeCommentData(plan, req, guardrails, viewerURL), req, state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute the comment template: %w", err)
+		}
+		// The default layout splits the comment or links to the plan viewer when it is too long.
+		if utf8.RuneCountInString(body) <= maxLength-commentHeaderRoom {
+			return []string{body}, nil
+		}
+		log.Printf("Fall back to the default comment layout because the templated one is too long: %s", req.RunID)
 	}
 
-	url, err := newGitURL(req.VCSPullRequestURL)
+	bodies, err := makeIssueComment(plan, req, guardrails, &commentOptions{
+		groupBy:   h.config.commentGroupBy,
+		viewerURL: viewerURL,
+		state:     state,
+		maxLength: maxLength,
+	})
 	if err != nil {
-		log.Printf("Unable to parse VCS pull request URL: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to make an issue comment: %w", err)
 	}
+	return bodies, nil
+}
 
-	var (
-		owner    = url.Owner()
-		repo     = url.Repository()
-		prNumber = url.PullRequest()
-	)
+func linksTo(bodies []string, url string) bool {
+	for _, body := range bodies {
+		if strings.Contains(body, url) {
+			return true
+		}
+	}
+	return false
+}
 
-	latestComment, err := findLatestComment(ctx, h.ghGraphQLClient, owner, repo, prNumber)
-	if err != nil && !errors.Is(err, errNotFound) {
-		log.Printf("Unable to query the previous comment to minimize: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+func (h *handler) pushApplyResult(ctx context.Context, req *TFERunTasksRequest) (*taskResult, error) {
+	appliedAt := time.Now()
+
+	p, url, err := h.pullRequest(req.VCSPullRequestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := findRunComment(ctx, p, url, req.RunID)
+	if errors.Is(err, errNotFound) {
+		log.Printf("Skip this run because the plan comment was not found: %s", req.RunID)
+		return &taskResult{
+			status:  "passed",
+			message: "Skipped because the plan result of this run was not found on VCS",
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to query the comment of the run: %w", err)
 	}
 
-	comment, err := makeIssueComment(plan, req.RunAppURL, req.VCSCommitURL)
+	outputs, err := fetchStateOutputs(ctx, h.httpClient, req.PlanJSONAPIURL, req.WorkspaceID, req.AccessToken)
 	if err != nil {
-		log.Printf("Failed to get the plan: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+		log.Printf("Unable to get the state outputs, so use the planned ones instead: %v", err)
+		plan, _, err := parsePlan(ctx, h.httpClient, req.PlanJSONAPIURL, req.AccessToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the plan: %w", err)
+		}
+		outputs = plannedOutputs(plan)
 	}
 
-	if err := createIssueComment(ctx, h.ghClient, owner, repo, prNumber, comment); err != nil {
-		log.Printf("Failed to create an issue comment: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+	// The status is limited to the room left in the comment without the one of the previous apply.
+	room := p.maxCommentLength() - utf8.RuneCountInString(withApplyStatus(comment.Body, ""))
+	// The applied plan no longer needs to be reviewed, so that the comment is updated as the non-destructive one.
+	body := withApplyStatus(comment.Body, makeApplyStatus(appliedAt, outputs, room))
+	if err := p.updateComment(ctx, url, comment, &vcsNewComment{Body: body}); err != nil {
+		return nil, fmt.Errorf("failed to update the issue comment: %w", err)
 	}
 
-	if latestComment != nil && bool(!latestComment.IsMinimized) {
-		if err := minimizeComment(ctx, h.ghGraphQLClient, latestComment.ID, "OUTDATED"); err != nil {
-			log.Printf("Failed to minimize comment: %v", err)
-			return
+	return &taskResult{
+		status:  "passed",
+		message: "Succeeded pushing the apply result to VCS",
+	}, nil
+}
+
+const (
+	commentModeNew    = "new"
+	commentModeSticky = "sticky"
+)
+
+// In the sticky mode, the previous comments are edited instead of hidden, except the surplus ones.
+func (h *handler) postComments(ctx context.Context, p vcsProvider, url gitURL, req *TFERunTasksRequest, comments []*vcsNewComment) error {
+	latestComments, err := findLatestComments(ctx, p, url, req.WorkspaceID)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return fmt.Errorf("unable to query the previous comment to minimize: %w", err)
+	}
+
+	var visibleComments []*vcsComment
+	for _, c := range latestComments {
+		if !c.Hidden {
+			visibleComments = append(visibleComments, c)
 		}
 	}
 
-	msg := "Succeeded pushing the plan result to VCS"
-	if err := h.sendCallback(ctx, req.TaskResultCallbackURL, req.AccessToken, msg); err != nil {
-		log.Printf("Failed to send callback to TFC: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
-		return
+	var edited int
+	if h.config.commentMode == commentModeSticky && len(latestComments) > 0 && !latestComments[0].Hidden {
+		edited = min(len(comments), len(visibleComments))
+		for i := 0; i < edited; i++ {
+			update := comments[i]
+			if i == 0 {
+				update = &vcsNewComment{
+					Body:        withHistory(update.Body, visibleComments[0].Body, req.RunID, p.maxCommentLength()),
+					Destructive: update.Destructive,
+				}
+			}
+			if err := p.updateComment(ctx, url, visibleComments[i], update); err != nil {
+				return fmt.Errorf("failed to update the issue comment: %w", err)
+			}
+		}
+	}
+
+	for _, comment := range comments[edited:] {
+		if err := p.createComment(ctx, url, comment); err != nil {
+			return fmt.Errorf("failed to create an issue comment: %w", err)
+		}
 	}
+
+	hideComments(ctx, p, url, visibleComments[edited:])
+	return nil
 }
 
-func (h *handler) sendCallback(ctx context.Context, url, token, message string) error {
+func hideComments(ctx context.Context, p vcsProvider, url gitURL, comments []*vcsComment) {
+	for _, comment := range comments {
+		if err := p.hideComment(ctx, url, comment); err != nil {
+			log.Printf("Failed to minimize comment: %v", err)
+		}
+	}
+}
+
+const callbackTimeout = 30 * time.Second
+
+// The callback is sent even after the deadline, since the failure caused by the deadline must be reported as well.
+func (h *handler) sendCallback(ctx context.Context, url, token string, result *taskResult) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), callbackTimeout)
+	defer cancel()
+
 	data := &TFERunTasksResponse{
 		Data: &TFERunTasksResponseData{
 			Type: "task-results",
 			Attributes: &TFERunTasksResponseAttributes{
-				Status:  "passed",
-				Message: message,
+				Status:  result.status,
+				Message: result.message,
 			},
 		},
 	}
+	if len(result.outcomes) > 0 {
+		data.Data.Relationships = &TFERunTasksResponseRelationships{
+			Outcomes: &TFERunTasksResponseOutcomes{
+				Data: result.outcomes,
+			},
+		}
+	}
 
 	buf := &bytes.Buffer{}
 	if err := json.NewEncoder(buf).Encode(data); err != nil {
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProcessRunTaskSendsCallbackAfterDeadline(t *testing.T) {
+	var got TFERunTasksResponse
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode the callback: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	h := newHandler(nil, nil, &handlerConfig{})
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	req := &TFERunTasksRequest{RunID: "run-1", AccessToken: "token", TaskResultCallbackURL: srv.URL}
+	if err := h.processRunTask(ctx, req); err != nil {
+		t.Fatalf("failed to process the run task: %v", err)
+	}
+	if got.Data == nil || got.Data.Attributes == nil || got.Data.Attributes.Status != "passed" {
+		t.Errorf("unexpected callback: %+v", got.Data)
+	}
+}
+
+func TestHandlerPullRequest(t *testing.T) {
+	providers := map[string]vcsProvider{
+		"github.com":            &githubProvider{},
+		"github.example.com":    &githubProvider{},
+		"gitlab.example.com":    &gitlabProvider{},
+		"bitbucket.org":         &bitbucketCloudProvider{},
+		"bitbucket.example.com": &bitbucketDataCenterProvider{},
+		"dev.azure.com":         &azureProvider{},
+	}
+	h := newHandler(providers, nil, &handlerConfig{})
+
+	tests := []struct {
+		url  string
+		want *gitURLWant
+	}{
+		// The paths looking like the ones of the other VCSs are parsed by the provider of the host.
+		{url: "https://github.com/o/pull-requests/pull/1", want: &gitURLWant{"github.com", "o", "pull-requests", 1}},
+		{url: "https://github.example.com/o/_git/pull/2", want: &gitURLWant{"github.example.com", "o", "_git", 2}},
+		{url: "https://gitlab.example.com/group/pull-requests/-/merge_requests/3", want: &gitURLWant{"gitlab.example.com", "group", "pull-requests", 3}},
+		{url: "https://bitbucket.org/w/r/pull-requests/4", want: &gitURLWant{"bitbucket.org", "w", "r", 4}},
+		{url: "https://bitbucket.example.com/projects/K/repos/r/pull-requests/5", want: &gitURLWant{"bitbucket.example.com", "K", "r", 5}},
+		{url: "https://dev.azure.com/o/p/_git/r/pullrequest/6", want: &gitURLWant{"dev.azure.com", "o/p", "r", 6}},
+		{url: "https://github.com/o/r/-/merge_requests/1"},
+		{url: "https://unknown.example.com/o/r/pull/1"},
+	}
+	for _, tt := range tests {
+		_, got, err := h.pullRequest(tt.url)
+		assertGitURL(t, tt.url, got, err, tt.want)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+type hostConfig interface {
+	host() string
+}
+
+func loadHostConfigs[T hostConfig](path string) ([]T, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []T
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, err
+	}
+	for _, c := range configs {
+		if c.host() == "" {
+			return nil, errors.New("missing host")
+		}
+	}
+	return configs, nil
+}
+
+func registerProviders[T hostConfig, P vcsProvider](providers map[string]vcsProvider, vcs string, configs []T, newProvider func(T) (P, error)) error {
+	for _, c := range configs {
+		if _, ok := providers[c.host()]; ok {
+			return fmt.Errorf("duplicated %s host config: %s", vcs, c.host())
+		}
+		p, err := newProvider(c)
+		if err != nil {
+			return fmt.Errorf("failed to create %s client for %s: %w", vcs, c.host(), err)
+		}
+		providers[c.host()] = p
+	}
+	return nil
+}
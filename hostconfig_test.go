@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadHostConfigs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	configs, err := loadHostConfigs[*githubHostConfig](write("ok.json", `[{"host": "github.example.com", "oauth_token": "t"}]`))
+	if err != nil {
+		t.Fatalf("failed to load the configs: %v", err)
+	}
+	if len(configs) != 1 || configs[0].Host != "github.example.com" || configs[0].OAuthToken != "t" {
+		t.Errorf("configs = %+v", configs)
+	}
+
+	if configs, err := loadHostConfigs[*githubHostConfig](""); err != nil || configs != nil {
+		t.Errorf("loadHostConfigs(\"\") = %v, %v, want nothing", configs, err)
+	}
+	if _, err := loadHostConfigs[*githubHostConfig](write("nohost.json", `[{"oauth_token": "t"}]`)); err == nil {
+		t.Error("the config without host is loaded")
+	}
+	if _, err := loadHostConfigs[*githubHostConfig](write("invalid.json", `{`)); err == nil {
+		t.Error("the invalid config is loaded")
+	}
+}
+
+func TestRegisterProviders(t *testing.T) {
+	providers := make(map[string]vcsProvider)
+	configs := []*githubHostConfig{{Host: "github.example.com", OAuthToken: "t"}}
+	if err := registerProviders(providers, "GitHub", configs, newGithubProvider); err != nil {
+		t.Fatalf("failed to register the providers: %v", err)
+	}
+	if _, ok := providers["github.example.com"].(*githubProvider); !ok {
+		t.Errorf("providers = %v", providers)
+	}
+
+	err := registerProviders(providers, "GitHub", configs, newGithubProvider)
+	if err == nil || !strings.Contains(err.Error(), "duplicated GitHub host config") {
+		t.Errorf("registering the duplicated host = %v", err)
+	}
+
+	configs = []*githubHostConfig{{Host: "github2.example.com"}}
+	err = registerProviders(providers, "GitHub", configs, newGithubProvider)
+	if err == nil || !strings.Contains(err.Error(), "missing an authentication config") {
+		t.Errorf("registering the host without credentials = %v", err)
+	}
+
+	// A host cannot be shared by the VCSs.
+	bitbucketConfigs := []*bitbucketHostConfig{{Host: "github.example.com", Token: "t"}}
+	err = registerProviders(providers, "Bitbucket", bitbucketConfigs, newBitbucketProvider)
+	if err == nil || !strings.Contains(err.Error(), "duplicated Bitbucket host config") {
+		t.Errorf("registering the host of another VCS = %v", err)
+	}
+}
@@ -2,17 +2,17 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
-
-	"github.com/bradleyfalzon/ghinstallation/v2"
-	"github.com/google/go-github/v56/github"
-	"github.com/shurcooL/githubv4"
-	"golang.org/x/oauth2"
+	"syscall"
+	"text/template"
+	"time"
 )
 
 func main() {
@@ -24,26 +24,22 @@ func main() {
 	}
 	log.Printf("Listening on HTTP port: %s", port)
 
+	configs, err := loadHostConfigs[*githubHostConfig](os.Getenv("GITHUB_HOSTS_CONFIG"))
+	if err != nil {
+		log.Fatalf("Failed to load GitHub hosts config: %v", err)
+	}
+
 	token := os.Getenv("GITHUB_OAUTH_TOKEN")
 	ghAppID := os.Getenv("GITHUB_APP_ID")
 	ghAppKey := os.Getenv("GITHUB_APP_PRIVATE_KEY")
 	ghAppInstallationID := os.Getenv("GITHUB_APP_INSTALLATION_ID")
-	if token == "" && (ghAppID == "" || ghAppKey == "" || ghAppInstallationID == "") {
-		log.Fatal("Missing an authentication config for GitHub")
-	}
-
-	var (
-		ghClient        *github.Client
-		ghGraphQLClient *githubv4.Client
-	)
-	if token != "" {
-		sts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: token},
-		)
-		c := oauth2.NewClient(context.Background(), sts)
-		ghClient = github.NewClient(c)
-		ghGraphQLClient = githubv4.NewClient(c)
-	} else {
+	switch {
+	case token != "":
+		configs = append(configs, &githubHostConfig{
+			Host:       GITHUB_HOST,
+			OAuthToken: token,
+		})
+	case ghAppID != "" && ghAppKey != "" && ghAppInstallationID != "":
 		appID, err := strconv.ParseInt(ghAppID, 10, 64)
 		if err != nil {
 			log.Fatalf("Invalid GitHub App id: %v", err)
@@ -52,21 +48,224 @@ func main() {
 		if err != nil {
 			log.Fatalf("Invalid GitHub App installation id: %v", err)
 		}
-		key, err := base64.StdEncoding.DecodeString(ghAppKey)
+		configs = append(configs, &githubHostConfig{
+			Host:              GITHUB_HOST,
+			AppID:             appID,
+			AppPrivateKey:     ghAppKey,
+			AppInstallationID: installationID,
+		})
+	}
+	providers := make(map[string]vcsProvider)
+	if err := registerProviders(providers, "GitHub", configs, newGithubProvider); err != nil {
+		log.Fatalf("Failed to register VCS providers: %v", err)
+	}
+
+	gitlabConfigs, err := loadHostConfigs[*gitlabHostConfig](os.Getenv("GITLAB_HOSTS_CONFIG"))
+	if err != nil {
+		log.Fatalf("Failed to load GitLab hosts config: %v", err)
+	}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		gitlabConfigs = append(gitlabConfigs, &gitlabHostConfig{
+			Host:  GITLAB_HOST,
+			Token: token,
+		})
+	}
+	if err := registerProviders(providers, "GitLab", gitlabConfigs, newGitlabProvider); err != nil {
+		log.Fatalf("Failed to register VCS providers: %v", err)
+	}
+
+	bitbucketConfigs, err := loadHostConfigs[*bitbucketHostConfig](os.Getenv("BITBUCKET_HOSTS_CONFIG"))
+	if err != nil {
+		log.Fatalf("Failed to load Bitbucket hosts config: %v", err)
+	}
+	bbToken := os.Getenv("BITBUCKET_TOKEN")
+	bbUsername := os.Getenv("BITBUCKET_USERNAME")
+	bbAppPassword := os.Getenv("BITBUCKET_APP_PASSWORD")
+	if bbToken != "" || (bbUsername != "" && bbAppPassword != "") {
+		bitbucketConfigs = append(bitbucketConfigs, &bitbucketHostConfig{
+			Host:        BITBUCKET_HOST,
+			Token:       bbToken,
+			Username:    bbUsername,
+			AppPassword: bbAppPassword,
+		})
+	}
+	if err := registerProviders(providers, "Bitbucket", bitbucketConfigs, newBitbucketProvider); err != nil {
+		log.Fatalf("Failed to register VCS providers: %v", err)
+	}
+
+	azureConfigs, err := loadHostConfigs[*azureHostConfig](os.Getenv("AZURE_DEVOPS_HOSTS_CONFIG"))
+	if err != nil {
+		log.Fatalf("Failed to load Azure DevOps hosts config: %v", err)
+	}
+	if token := os.Getenv("AZURE_DEVOPS_TOKEN"); token != "" {
+		azureConfigs = append(azureConfigs, &azureHostConfig{
+			Host:  AZURE_HOST,
+			Token: token,
+		})
+	}
+	if err := registerProviders(providers, "Azure DevOps", azureConfigs, newAzureProvider); err != nil {
+		log.Fatalf("Failed to register VCS providers: %v", err)
+	}
+
+	if len(providers) == 0 {
+		log.Fatal("Missing an authentication config for VCS")
+	}
+
+	workers, err := intEnv("WORKER_COUNT", 4)
+	if err != nil {
+		log.Fatalf("Invalid number of workers: %v", err)
+	}
+	queueSize, err := intEnv("WORKER_QUEUE_SIZE", 100)
+	if err != nil {
+		log.Fatalf("Invalid worker queue size: %v", err)
+	}
+	// TFC/E waits for the callback up to 10 minutes, so the run task is given up well before it to report the failure.
+	taskTimeout := 5 * time.Minute
+	if v := os.Getenv("RUN_TASK_TIMEOUT"); v != "" {
+		taskTimeout, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Invalid run task timeout: %v", err)
+		}
+	}
+
+	outcomesGroupBy := os.Getenv("OUTCOMES_GROUP_BY")
+	switch outcomesGroupBy {
+	case "":
+		outcomesGroupBy = outcomesGroupByResource
+	case outcomesGroupByResource, outcomesGroupByAction:
+	default:
+		log.Fatalf("Invalid outcomes grouping: %s", outcomesGroupBy)
+	}
+
+	commentMode := os.Getenv("COMMENT_MODE")
+	switch commentMode {
+	case "":
+		commentMode = commentModeNew
+	case commentModeNew, commentModeSticky:
+	default:
+		log.Fatalf("Invalid comment mode: %s", commentMode)
+	}
+
+	commentGroupBy := os.Getenv("COMMENT_GROUP_BY")
+	switch commentGroupBy {
+	case "":
+		commentGroupBy = commentGroupByNone
+	case commentGroupByNone, commentGroupByModule, commentGroupByAction:
+	default:
+		log.Fatalf("Invalid comment grouping: %s", commentGroupBy)
+	}
+
+	guardrails, err := parseGuardrails(os.Getenv("GUARDRAILS"))
+	if err != nil {
+		log.Fatalf("Invalid guardrails: %v", err)
+	}
+
+	var commentTemplate *template.Template
+	if path := os.Getenv("COMMENT_TEMPLATE"); path != "" {
+		commentTemplate, err = loadCommentTemplate(path)
 		if err != nil {
-			log.Fatalf("Failed to decode GitHub App private key: %v", err)
+			log.Fatalf("Failed to load the comment template: %v", err)
 		}
-		itr, err := ghinstallation.New(http.DefaultTransport, appID, installationID, key)
+	}
+
+	var viewer *planViewer
+	if viewerURL := os.Getenv("PLAN_VIEWER_URL"); viewerURL != "" {
+		secret := os.Getenv("PLAN_VIEWER_SECRET")
+		if secret == "" {
+			log.Fatal("Missing a secret to sign the plan viewer links")
+		}
+
+		ttl := 30 * 24 * time.Hour
+		if v := os.Getenv("PLAN_VIEWER_LINK_TTL"); v != "" {
+			ttl, err = time.ParseDuration(v)
+			if err != nil {
+				log.Fatalf("Invalid plan viewer link TTL: %v", err)
+			}
+		}
+
+		kind := os.Getenv("PLAN_VIEWER_STORAGE")
+		if kind == "" {
+			kind = planStoreFile
+		}
+		path := os.Getenv("PLAN_VIEWER_STORAGE_PATH")
+		if path == "" {
+			path = "plans"
+			if kind == planStoreBolt {
+				path = "plans.db"
+			}
+		}
+		store, err := newPlanStore(kind, path)
 		if err != nil {
-			log.Fatalf("Failed to create GitHub client authenticated by GitHub App: %v", err)
+			log.Fatalf("Failed to open the plan viewer storage: %v", err)
+		}
+		defer store.close()
+
+		viewer = &planViewer{
+			baseURL: viewerURL,
+			secret:  []byte(secret),
+			ttl:     ttl,
+			store:   store,
+		}
+	}
+
+	pool := newWorkerPool(workers, queueSize)
+	handler := newHandler(providers, pool, &handlerConfig{
+		taskTimeout:     taskTimeout,
+		outcomesGroupBy: outcomesGroupBy,
+		commentMode:     commentMode,
+		commentGroupBy:  commentGroupBy,
+		guardrails:      guardrails,
+		viewer:          viewer,
+		commentTemplate: commentTemplate,
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler.handleRunTask)
+	if viewer != nil {
+		mux.HandleFunc(planViewerPath, viewer.handlePlan)
+	}
+	server := &http.Server{
+		Addr:    net.JoinHostPort("", port),
+		Handler: mux,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
 		}
-		c := &http.Client{Transport: itr}
-		ghClient = github.NewClient(c)
-		ghGraphQLClient = githubv4.NewClient(c)
+	}()
+
+	if viewer != nil {
+		go viewer.pruneEvery(ctx, time.Hour)
 	}
 
-	handler := newHandler(ghClient, ghGraphQLClient)
-	http.HandleFunc("/", handler.handleRunTask)
+	<-ctx.Done()
+	log.Println("Shutting down...")
 
-	log.Fatal(http.ListenAndServe(net.JoinHostPort("", port), nil))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), taskTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shutdown the server: %v", err)
+	}
+	if err := pool.shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to drain the in-flight run tasks: %v", err)
+	}
+}
+
+func intEnv(key string, defaultValue int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("%s must be positive: %d", key, n)
+	}
+	return n, nil
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+)
+
+const maskedValue = "(sensitive value)"
+
+// sensitiveValue keeps only the digest, so that a changed sensitive value is still rendered as changed.
+type sensitiveValue struct {
+	digest [sha256.Size]byte
+}
+
+func (sensitiveValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(maskedValue)
+}
+
+func newSensitiveValue(value interface{}) sensitiveValue {
+	// The value decoded from the plan JSON can always be encoded again.
+	data, _ := json.Marshal(value)
+	return sensitiveValue{digest: sha256.Sum256(data)}
+}
+
+// When the sensitive tree doesn't match the structure of the value, the whole value is masked if any part of it is sensitive.
+// A null value is kept as is, since it reveals nothing.
+func maskSensitiveValues(value, sensitive interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if s, ok := sensitive.(bool); ok && s {
+		return newSensitiveValue(value)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		s, ok := sensitive.(map[string]interface{})
+		if !ok && hasMarks(sensitive) {
+			return newSensitiveValue(value)
+		}
+		masked := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			masked[k] = maskSensitiveValues(e, s[k])
+		}
+		return masked
+	case []interface{}:
+		s, ok := sensitive.([]interface{})
+		if !ok && hasMarks(sensitive) {
+			return newSensitiveValue(value)
+		}
+		masked := make([]interface{}, len(v))
+		for i, e := range v {
+			var es interface{}
+			if i < len(s) {
+				es = s[i]
+			}
+			masked[i] = maskSensitiveValues(e, es)
+		}
+		return masked
+	default:
+		if hasMarks(sensitive) {
+			return newSensitiveValue(value)
+		}
+		return value
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// sensitiveTreeGenerator generates a random value with its sensitive tree, where the sensitive leaves contain "SECRET".
+// The sensitive tree only matches the value along the paths to the sensitive parts, and the other parts have
+// random shapes to make sure the mismatched trees neither reveal the sensitive values nor break the rendering.
+type sensitiveTreeGenerator struct {
+	r       *rand.Rand
+	secrets int
+}
+
+// value returns the value and its sensitive tree.
+func (g *sensitiveTreeGenerator) value(depth int) (interface{}, interface{}) {
+	if g.r.Intn(5) == 0 {
+		return g.secret()
+	}
+
+	kind := g.r.Intn(7)
+	if depth > 3 {
+		kind = g.r.Intn(4)
+	}
+	switch kind {
+	case 0:
+		return nil, g.noise(depth)
+	case 1:
+		return float64(g.r.Intn(3)), g.noise(depth)
+	case 2:
+		return g.r.Intn(2) == 0, g.noise(depth)
+	case 3:
+		return fmt.Sprintf("public%d", g.r.Intn(3)), g.noise(depth)
+	case 4, 5:
+		value, sensitive := map[string]interface{}{}, map[string]interface{}{}
+		for i := g.r.Intn(4); i > 0; i-- {
+			k := fmt.Sprintf("k%d", g.r.Intn(4))
+			value[k], sensitive[k] = g.value(depth + 1)
+		}
+		// The sensitive tree can have the keys missing in the value.
+		if g.r.Intn(4) == 0 {
+			sensitive["extra"] = g.noise(depth + 1)
+		}
+		return value, sensitive
+	default:
+		var value, sensitive []interface{}
+		for i := g.r.Intn(4); i > 0; i-- {
+			v, s := g.value(depth + 1)
+			value, sensitive = append(value, v), append(sensitive, s)
+		}
+		// The sensitive tree can be shorter or longer than the value, as long as the sensitive elements are marked.
+		if g.r.Intn(4) == 0 {
+			sensitive = append(sensitive, g.noise(depth+1))
+		}
+		return value, sensitive
+	}
+}
+
+// secret returns the sensitive value marked by true, or by a tree of another shape having a mark.
+func (g *sensitiveTreeGenerator) secret() (interface{}, interface{}) {
+	var value interface{}
+	switch g.r.Intn(3) {
+	case 0:
+		value = g.secretLeaf()
+	case 1:
+		value = map[string]interface{}{"k0": g.secretLeaf(), "k1": []interface{}{g.secretLeaf()}}
+	default:
+		value = []interface{}{g.secretLeaf(), map[string]interface{}{"k0": g.secretLeaf()}}
+	}
+
+	if g.r.Intn(2) == 0 {
+		return value, true
+	}
+	// The tree of another shape masks the whole value.
+	if _, ok := value.([]interface{}); ok {
+		return value, map[string]interface{}{"other": true}
+	}
+	return value, []interface{}{false, true}
+}
+
+func (g *sensitiveTreeGenerator) secretLeaf() string {
+	g.secrets++
+	return fmt.Sprintf("SECRET%d", g.secrets)
+}
+
+// noise returns a random sensitive tree, which may mark the value as sensitive.
+func (g *sensitiveTreeGenerator) noise(depth int) interface{} {
+	switch g.r.Intn(6) {
+	case 0:
+		return nil
+	case 1:
+		return g.r.Intn(4) == 0
+	case 2:
+		if depth > 3 {
+			return false
+		}
+		return map[string]interface{}{"k0": g.noise(depth + 1), "k1": g.noise(depth + 1)}
+	case 3:
+		if depth > 3 {
+			return false
+		}
+		return []interface{}{g.noise(depth + 1), g.noise(depth + 1)}
+	case 4:
+		return "unexpected"
+	default:
+		return float64(1)
+	}
+}
+
+func copyTree(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = copyTree(e)
+		}
+		return m
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = copyTree(e)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+func FuzzMaskSensitiveValues(f *testing.F) {
+	for seed := int64(0); seed < 100; seed++ {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		g := &sensitiveTreeGenerator{r: rand.New(rand.NewSource(seed))}
+		before, beforeSensitive := g.value(0)
+		after, afterSensitive := g.value(0)
+		afterUnknown := g.noise(0)
+
+		change := &tfjson.Change{
+			Actions:         tfjson.Actions{tfjson.ActionUpdate},
+			Before:          map[string]interface{}{"attr": before},
+			After:           map[string]interface{}{"attr": after},
+			BeforeSensitive: map[string]interface{}{"attr": beforeSensitive},
+			AfterSensitive:  map[string]interface{}{"attr": afterSensitive},
+			AfterUnknown:    map[string]interface{}{"attr": afterUnknown},
+		}
+		original := copyTree(map[string]interface{}{
+			"before":           change.Before,
+			"after":            change.After,
+			"before_sensitive": change.BeforeSensitive,
+			"after_sensitive":  change.AfterSensitive,
+			"after_unknown":    change.AfterUnknown,
+		})
+
+		c := &tfjson.ResourceChange{
+			Address: "null_resource.fuzz",
+			Mode:    tfjson.ManagedResourceMode,
+			Type:    "null_resource",
+			Name:    "fuzz",
+			Change:  change,
+		}
+		outputChange := &tfjson.Change{
+			Actions:         change.Actions,
+			Before:          before,
+			After:           after,
+			BeforeSensitive: beforeSensitive,
+			AfterSensitive:  afterSensitive,
+			AfterUnknown:    afterUnknown,
+		}
+		for name, rendered := range map[string]string{
+			"resource": renderResourceChange(c, Update),
+			"output":   renderOutputChange("fuzz", outputChange),
+		} {
+			if strings.Contains(rendered, "SECRET") {
+				t.Errorf("the %s change reveals a sensitive value:\n%s", name, rendered)
+			}
+		}
+
+		got := map[string]interface{}{
+			"before":           change.Before,
+			"after":            change.After,
+			"before_sensitive": change.BeforeSensitive,
+			"after_sensitive":  change.AfterSensitive,
+			"after_unknown":    change.AfterUnknown,
+		}
+		if !reflect.DeepEqual(got, original) {
+			t.Errorf("the plan is mutated:\ngot  %#v\nwant %#v", got, original)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+// Package metadata reads and writes the plan summary embedded in the PR comments of runtasks-pr-comment.
+//
+// The summary is a JSON object put in a hidden HTML comment next to the tag identifying the PR comment,
+// so that bots can read the changes of the plan without scraping the rendered Markdown.
+//
+//	m, err := metadata.Parse(comment.Body)
+//	if errors.Is(err, metadata.ErrNotFound) {
+//		// The comment isn't a plan comment.
+//	}
+//	if m.Summary.Destroy > 0 {
+//		// ...
+//	}
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Version is the version of the format. It is only bumped for an incompatible change,
+// while the fields may be added without bumping it.
+const Version = 1
+
+const tagFormat = "<!-- runtasks-pr-comment-metadata: %s -->"
+
+var tagPattern = regexp.MustCompile(`<!-- runtasks-pr-comment-metadata: (.*?) -->`)
+
+// ErrNotFound is returned when the comment doesn't carry the metadata.
+var ErrNotFound = errors.New("metadata not found")
+
+// Metadata is the summary of the plan commented on the pull request.
+type Metadata struct {
+	Version   int       `json:"version"`
+	RunID     string    `json:"run_id"`
+	RunURL    string    `json:"run_url,omitempty"`
+	Workspace Workspace `json:"workspace"`
+	// Commit is the SHA of the commit which triggered the run.
+	Commit    string    `json:"commit"`
+	CommitURL string    `json:"commit_url,omitempty"`
+	Summary   Summary   `json:"summary"`
+	Addresses Addresses `json:"addresses"`
+	// Truncated tells the address lists are dropped to fit in the comment, while the summary is still complete.
+	Truncated bool `json:"truncated,omitempty"`
+	// PlanHash is the SHA-256 of the plan JSON prefixed with "sha256:", which is empty when it is unknown.
+	PlanHash string `json:"plan_hash,omitempty"`
+}
+
+type Workspace struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Summary is the number of the resources to change like `terraform plan`.
+// A replaced resource is counted in Add and Destroy as well as in Replace.
+type Summary struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+	Import  int `json:"import"`
+	Replace int `json:"replace"`
+	Move    int `json:"move"`
+}
+
+// Addresses lists the addresses of the resources by the action in the order of the plan.
+// A resource can be listed in both of Import or Move and the action changing it.
+type Addresses struct {
+	Create  []string `json:"create,omitempty"`
+	Update  []string `json:"update,omitempty"`
+	Delete  []string `json:"delete,omitempty"`
+	Replace []string `json:"replace,omitempty"`
+	Read    []string `json:"read,omitempty"`
+	Import  []string `json:"import,omitempty"`
+	Move    []string `json:"move,omitempty"`
+}
+
+// Format encodes the metadata into the hidden HTML comment.
+func Format(m *Metadata) (string, error) {
+	// The JSON never terminates the HTML comment, since json.Marshal escapes ">" in the strings.
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(tagFormat, data), nil
+}
+
+// Parse reads the metadata out of the comment body. It returns ErrNotFound when the body doesn't
+// carry the metadata, and an error when its version isn't supported.
+func Parse(body string) (*Metadata, error) {
+	match := tagPattern.FindStringSubmatch(body)
+	if match == nil {
+		return nil, ErrNotFound
+	}
+
+	var m Metadata
+	if err := json.Unmarshal([]byte(match[1]), &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the metadata: %w", err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("unsupported metadata version: %d", m.Version)
+	}
+	return &m, nil
+}
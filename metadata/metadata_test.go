@@ -0,0 +1,86 @@
+package metadata
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testMetadata() *Metadata {
+	return &Metadata{
+		Version:   Version,
+		RunID:     "run-1",
+		RunURL:    "https://app.terraform.io/app/org/workspaces/ws/runs/run-1",
+		Workspace: Workspace{ID: "ws-1", Name: "production"},
+		Commit:    "0123abc",
+		Summary:   Summary{Add: 1, Destroy: 1, Replace: 1},
+		Addresses: Addresses{
+			Replace: []string{`aws_instance.web["-->"]`},
+			Move:    []string{"module.a.null_resource.b"},
+		},
+		PlanHash: "sha256:abc",
+	}
+}
+
+func TestFormatParse(t *testing.T) {
+	want := testMetadata()
+	formatted, err := Format(want)
+	if err != nil {
+		t.Fatalf("failed to format the metadata: %v", err)
+	}
+	// The address containing "-->" must not terminate the HTML comment.
+	if strings.Count(formatted, "-->") != 1 {
+		t.Errorf("the HTML comment is terminated early: %s", formatted)
+	}
+
+	got, err := Parse(formatted)
+	if err != nil {
+		t.Fatalf("failed to parse the metadata: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	formatted, err := Format(testMetadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsupported, err := Format(&Metadata{Version: Version + 1, RunID: "run-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		notFound bool
+		wantErr  bool
+	}{
+		{
+			name: "wrapped in the comment",
+			body: "<!-- runtasks-pr-comment -->\n<!-- runtasks-pr-comment-workspace: ws-1 production -->\n" + formatted +
+				"\n## Terraform Plan\n<details>\n<summary>Changes</summary>\n\n<!-- other -->\n</details>\n",
+		},
+		{name: "no metadata", body: "<!-- runtasks-pr-comment -->\n## Terraform Plan\n", notFound: true, wantErr: true},
+		{name: "empty", body: "", notFound: true, wantErr: true},
+		{name: "unsupported version", body: unsupported, wantErr: true},
+		{name: "invalid JSON", body: "<!-- runtasks-pr-comment-metadata: {\"version\": -->", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.body)
+			if errors.Is(err, ErrNotFound) != tt.notFound {
+				t.Errorf("Parse() = %v, want ErrNotFound: %v", err, tt.notFound)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() = %v, want an error: %v", err, tt.wantErr)
+			}
+			if err == nil && m.RunID != "run-1" {
+				t.Errorf("run ID = %q, want run-1", m.RunID)
+			}
+		})
+	}
+}
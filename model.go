@@ -11,7 +11,7 @@ type TFERunTasksRequest struct {
 	ConfigurationVersionDownloadURL string                   `json:"configuration_version_download_url,omitempty"`
 	ConfigurationVersionID          string                   `json:"configuration_version_id,omitempty"`
 	IsSpeculative                   bool                     `json:"is_speculative,omitempty"`
-	OrganizationName                string                   `json:"organization_nam,omitemptye"`
+	OrganizationName                string                   `json:"organization_name,omitempty"`
 	PlanJSONAPIURL                  string                   `json:"plan_json_api_url,omitempty"`
 	RunAppURL                       string                   `json:"run_app_url,omitempty"`
 	RunCreatedAt                    time.Time                `json:"run_created_at,omitempty"`
@@ -56,7 +56,12 @@ type TFERunTasksResponseRelationships struct {
 }
 
 type TFERunTasksResponseOutcomes struct {
-	Data []*TFERunTasksResponseOutcomesData `json:"data,omitempty"`
+	Data []*TFERunTasksResponseOutcome `json:"data,omitempty"`
+}
+
+type TFERunTasksResponseOutcome struct {
+	Type       string                           `json:"type,omitempty"`
+	Attributes *TFERunTasksResponseOutcomesData `json:"attributes,omitempty"`
 }
 
 type TFERunTasksResponseOutcomesData struct {
@@ -80,3 +85,21 @@ type TFERunTasksResponseOutcomesTags struct {
 	Label string                `json:"label,omitempty"`
 	Level TFERunTasksErrorLevel `json:"level,omitempty"`
 }
+
+// https://developer.hashicorp.com/terraform/cloud-docs/api-docs/state-version-outputs#show-current-state-version-outputs-for-a-workspace
+type TFEStateVersionOutputsResponse struct {
+	Data []*TFEStateVersionOutputsData `json:"data,omitempty"`
+}
+
+type TFEStateVersionOutputsData struct {
+	ID         string                 `json:"id,omitempty"`
+	Type       string                 `json:"type,omitempty"`
+	Attributes *TFEStateVersionOutput `json:"attributes,omitempty"`
+}
+
+type TFEStateVersionOutput struct {
+	Name      string      `json:"name,omitempty"`
+	Sensitive bool        `json:"sensitive,omitempty"`
+	Type      string      `json:"type,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTFERunTasksRequestOrganizationName(t *testing.T) {
+	var req TFERunTasksRequest
+	if err := json.Unmarshal([]byte(`{"organization_name":"acme","run_id":"run-1"}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.OrganizationName != "acme" {
+		t.Errorf("OrganizationName = %q, want %q", req.OrganizationName, "acme")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const (
+	outcomesGroupByResource = "resource"
+	outcomesGroupByAction   = "action"
+)
+
+// https://developer.hashicorp.com/terraform/cloud-docs/integrations/run-tasks#structured-results
+func makeOutcomes(plan *tfjson.Plan, runURL, groupBy string) []*TFERunTasksResponseOutcome {
+	if groupBy == outcomesGroupByAction {
+		return makeActionOutcomes(plan, runURL)
+	}
+	return makeResourceOutcomes(plan, runURL)
+}
+
+func makeResourceOutcomes(plan *tfjson.Plan, runURL string) []*TFERunTasksResponseOutcome {
+	var outcomes []*TFERunTasksResponseOutcome
+	for _, c := range plan.ResourceChanges {
+		if c.Change == nil {
+			continue
+		}
+
+		action := UnmarshalActions(c.Change.Actions)
+		if action == NoOp {
+			continue
+		}
+
+		// The reason is added unless the description already tells it, e.g. "is tainted, so must be replaced".
+		description, _ := describeResourceChange(c, action)
+		if reason := replacementReason(c, action); reason != "" && description == action.Description() {
+			description += fmt.Sprintf(" (%s)", reason)
+		}
+
+		outcomes = append(outcomes, &TFERunTasksResponseOutcome{
+			Type: "task-result-outcomes",
+			Attributes: &TFERunTasksResponseOutcomesData{
+				OutcomeID:   c.Address,
+				Description: fmt.Sprintf("%s %s", c.Address, description),
+				Body:        fmt.Sprintf("```diff\n%s\n```", renderResourceChange(c, action)),
+				URL:         runURL,
+				Tags:        outcomeTags(action),
+			},
+		})
+	}
+	return outcomes
+}
+
+func makeActionOutcomes(plan *tfjson.Plan, runURL string) []*TFERunTasksResponseOutcome {
+	groups := make(map[Action][]*tfjson.ResourceChange, len(actionOrder))
+	for _, c := range plan.ResourceChanges {
+		if c.Change == nil {
+			continue
+		}
+
+		action := UnmarshalActions(c.Change.Actions)
+		if action == NoOp {
+			continue
+		}
+		groups[action] = append(groups[action], c)
+	}
+
+	var outcomes []*TFERunTasksResponseOutcome
+	for _, action := range actionOrder {
+		changes := groups[action]
+		if len(changes) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		for _, c := range changes {
+			fmt.Fprintf(&b, "#### %s\n\n```diff\n%s\n```\n\n", c.Address, renderResourceChange(c, action))
+		}
+
+		outcomes = append(outcomes, &TFERunTasksResponseOutcome{
+			Type: "task-result-outcomes",
+			Attributes: &TFERunTasksResponseOutcomesData{
+				OutcomeID:   fmt.Sprintf("%s %s", action.Symbol(), action),
+				Description: fmt.Sprintf("%d resource(s) %s", len(changes), action.Description()),
+				Body:        b.String(),
+				URL:         runURL,
+				Tags:        outcomeTags(action),
+			},
+		})
+	}
+	return outcomes
+}
+
+func outcomeTags(action Action) map[string][]*TFERunTasksResponseOutcomesTags {
+	var severity *TFERunTasksResponseOutcomesTags
+	switch action {
+	// The unknown actions are reported as high, since they can be destructive.
+	case Delete, DeleteThenCreate, CreateThenDelete, Unknown:
+		severity = &TFERunTasksResponseOutcomesTags{Label: "High", Level: WARNING}
+	case Update:
+		severity = &TFERunTasksResponseOutcomesTags{Label: "Medium", Level: INFO}
+	default:
+		severity = &TFERunTasksResponseOutcomesTags{Label: "Low", Level: NONE}
+	}
+
+	return map[string][]*TFERunTasksResponseOutcomesTags{
+		"Action":   {{Label: action.String(), Level: severity.Level}},
+		"Severity": {severity},
+	}
+}
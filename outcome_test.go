@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+func TestMakeOutcomes(t *testing.T) {
+	change := func(address string, actions ...tfjson.Action) *tfjson.ResourceChange {
+		return &tfjson.ResourceChange{
+			Address: address, Mode: tfjson.ManagedResourceMode, Type: "null_resource", Name: address,
+			Change: &tfjson.Change{Actions: actions, Before: map[string]interface{}{}, After: map[string]interface{}{}},
+		}
+	}
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			change("created", tfjson.ActionCreate),
+			change("updated", tfjson.ActionUpdate),
+			change("unchanged", tfjson.ActionNoop),
+			change("deleted", tfjson.ActionDelete),
+			change("replaced", tfjson.ActionDelete, tfjson.ActionCreate),
+			change("created_too", tfjson.ActionCreate),
+			{Address: "no_change"},
+		},
+	}
+
+	type outcome struct {
+		id, description, severity string
+	}
+	tests := []struct {
+		groupBy string
+		want    []outcome
+	}{
+		{
+			groupBy: outcomesGroupByResource,
+			want: []outcome{
+				{"created", "created will be created", "Low"},
+				{"updated", "updated will be updated in-place", "Medium"},
+				{"deleted", "deleted will be destroyed", "High"},
+				{"replaced", "replaced must be replaced", "High"},
+				{"created_too", "created_too will be created", "Low"},
+			},
+		},
+		{
+			groupBy: outcomesGroupByAction,
+			want: []outcome{
+				{"- delete", "1 resource(s) will be destroyed", "High"},
+				{"-/+ replace", "1 resource(s) must be replaced", "High"},
+				{"~ update", "1 resource(s) will be updated in-place", "Medium"},
+				{"+ create", "2 resource(s) will be created", "Low"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			outcomes := makeOutcomes(plan, "https://app.terraform.io/runs/run-1", tt.groupBy)
+			if len(outcomes) != len(tt.want) {
+				t.Fatalf("outcomes = %d, want %d", len(outcomes), len(tt.want))
+			}
+			for i, o := range outcomes {
+				got := outcome{o.Attributes.OutcomeID, o.Attributes.Description, o.Attributes.Tags["Severity"][0].Label}
+				if got != tt.want[i] {
+					t.Errorf("outcomes[%d] = %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
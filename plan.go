@@ -2,22 +2,94 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	tfjson "github.com/hashicorp/terraform-json"
 )
 
 type ChangeSummary struct {
-	Add    int
-	Change int
-	Remove int
-	Import int
+	Add     int
+	Change  int
+	Remove  int
+	Import  int
+	Replace int
+	Move    int
+}
+
+func summarizeChanges(plan *tfjson.Plan) *ChangeSummary {
+	return summarizeResourceChanges(plan.ResourceChanges)
+}
+
+func summarizeResourceChanges(changes []*tfjson.ResourceChange) *ChangeSummary {
+	cs := &ChangeSummary{}
+	for _, c := range changes {
+		if c.Change == nil {
+			continue
+		}
+
+		if isMoved(c) {
+			cs.Move++
+		}
+
+		// An import can also change the resource, which is counted as well like Terraform.
+		if c.Change.Importing != nil {
+			cs.Import++
+		}
+
+		for _, a := range c.Change.Actions {
+			switch a {
+			case tfjson.ActionCreate:
+				cs.Add++
+			case tfjson.ActionUpdate:
+				cs.Change++
+			case tfjson.ActionDelete:
+				cs.Remove++
+			}
+		}
+
+		if c.Change.Actions.Replace() {
+			cs.Replace++
+		}
+	}
+	return cs
 }
 
 func (c *ChangeSummary) String() string {
+	s := fmt.Sprintf("+ %d to add, ~ %d to change, - %d to destroy", c.Add, c.Change, c.Remove)
 	if c.Import > 0 {
-		return fmt.Sprintf("& %d to import, + %d to add, ~ %d to change, - %d to destroy.", c.Import, c.Add, c.Change, c.Remove)
+		s = fmt.Sprintf("& %d to import, ", c.Import) + s
+	}
+	if c.Move > 0 {
+		s += fmt.Sprintf(", → %d to move", c.Move)
+	}
+	return s + "."
+}
+
+func (c *ChangeSummary) hasChanges() bool {
+	return c.Add+c.Change+c.Remove+c.Import+c.Move > 0
+}
+
+func isImported(c *tfjson.ResourceChange) bool {
+	return c.Change.Importing != nil && UnmarshalActions(c.Change.Actions) == NoOp
+}
+
+func importID(c *tfjson.ResourceChange) string {
+	if c.Change.Importing == nil {
+		return ""
+	}
+	return c.Change.Importing.ID
+}
+
+func actionNames(actions tfjson.Actions) []string {
+	names := make([]string, 0, len(actions))
+	for _, a := range actions {
+		names = append(names, string(a))
 	}
-	return fmt.Sprintf("+ %d to add, ~ %d to change, - %d to destroy.", c.Add, c.Change, c.Remove)
+	return names
+}
+
+func isMoved(c *tfjson.ResourceChange) bool {
+	return c.PreviousAddress != "" && c.PreviousAddress != c.Address
 }
 
 type Action rune
@@ -30,8 +102,13 @@ const (
 	DeleteThenCreate Action = '∓'
 	CreateThenDelete Action = '±'
 	Delete           Action = '-'
+	// Unknown is the actions added by a newer version of Terraform.
+	Unknown Action = '?'
 )
 
+// actionOrder puts the destructive actions first.
+var actionOrder = []Action{Unknown, Delete, DeleteThenCreate, CreateThenDelete, Update, Create, Read}
+
 func UnmarshalActions(actions tfjson.Actions) Action {
 	if len(actions) == 2 {
 		if actions[0] == "create" && actions[1] == "delete" {
@@ -58,7 +135,7 @@ func UnmarshalActions(actions tfjson.Actions) Action {
 		}
 	}
 
-	panic("unrecognized action slices")
+	return Unknown
 }
 
 func (a Action) Symbol() string {
@@ -78,6 +155,165 @@ func (a Action) Symbol() string {
 	case NoOp:
 		return "   "
 	default:
-		return "  ?"
+		return "?"
+	}
+}
+
+func (a Action) String() string {
+	switch a {
+	case DeleteThenCreate, CreateThenDelete:
+		return "replace"
+	case Create:
+		return "create"
+	case Delete:
+		return "delete"
+	case Read:
+		return "read"
+	case Update:
+		return "update"
+	case NoOp:
+		return "no-op"
+	default:
+		return "unknown"
+	}
+}
+
+func (a Action) Description() string {
+	switch a {
+	case DeleteThenCreate, CreateThenDelete:
+		return "must be replaced"
+	case Create:
+		return "will be created"
+	case Delete:
+		return "will be destroyed"
+	case Read:
+		return "will be read during apply"
+	case Update:
+		return "will be updated in-place"
+	case Unknown:
+		return "has an unsupported change"
+	default:
+		return "has no changes"
+	}
+}
+
+func describeResourceChange(c *tfjson.ResourceChange, action Action) (string, string) {
+	if c.DeposedKey != "" {
+		description := fmt.Sprintf("(deposed object %s) %s", c.DeposedKey, action.Description())
+		return description, "(left over from a partially-failed replacement of this instance)"
+	}
+	if action == NoOp && c.Change.Importing != nil {
+		return "will be imported", ""
+	}
+	if action == Unknown {
+		return action.Description(), fmt.Sprintf("(actions: %s)", strings.Join(actionNames(c.Change.Actions), ", "))
+	}
+
+	switch c.ActionReason {
+	case tfjson.ActionReasonReplaceBecauseTainted:
+		return "is tainted, so must be replaced", ""
+	case tfjson.ActionReasonReplaceByRequest:
+		return "will be replaced, as requested", ""
+	case tfjson.ActionReasonReplaceByTriggers:
+		return "will be replaced due to changes in replace_triggered_by", ""
+	case tfjson.ActionReasonDeleteBecauseNoResourceConfig:
+		return action.Description(), fmt.Sprintf("(because %s is not in configuration)", c.Address)
+	case tfjson.ActionReasonDeleteBecauseWrongRepetition:
+		if c.Index == nil {
+			return action.Description(), "(because resource uses count or for_each)"
+		}
+		return action.Description(), "(because resource does not use count or for_each)"
+	case tfjson.ActionReasonDeleteBecauseCountIndex:
+		return action.Description(), fmt.Sprintf("(because index [%v] is out of range for count)", c.Index)
+	case tfjson.ActionReasonDeleteBecauseEachKey:
+		return action.Description(), fmt.Sprintf("(because key [%q] is not in for_each map)", fmt.Sprint(c.Index))
+	case tfjson.ActionReasonDeleteBecauseNoModule:
+		return action.Description(), fmt.Sprintf("(because %s is not in configuration)", c.ModuleAddress)
+	case tfjson.ActionReasonDeleteBecauseNoMoveTarget:
+		return action.Description(), fmt.Sprintf("(because %s was moved to %s, which is not in configuration)", c.PreviousAddress, c.Address)
+	case tfjson.ActionReasonReadBecauseConfigUnknown:
+		return action.Description(), "(config refers to values not yet known)"
+	case tfjson.ActionReasonReadBecauseDependencyPending:
+		return action.Description(), "(depends on a resource or a module with changes pending)"
+	case tfjson.ActionReasonReadBecauseCheckNested:
+		return action.Description(), "(config will be reloaded to verify a check block)"
+	default:
+		return action.Description(), ""
+	}
+}
+
+// Without any specific reason, the resource is replaced because the changed attributes cannot be updated in-place.
+func replacementReason(c *tfjson.ResourceChange, action Action) string {
+	if action != DeleteThenCreate && action != CreateThenDelete {
+		return ""
+	}
+
+	switch c.ActionReason {
+	case tfjson.ActionReasonReplaceBecauseTainted:
+		return "tainted"
+	case tfjson.ActionReasonReplaceByRequest:
+		return "requested"
+	case tfjson.ActionReasonReplaceByTriggers:
+		return "replace_triggered_by"
+	}
+
+	var attrs []string
+	seen := make(map[string]bool)
+	for _, rp := range c.Change.ReplacePaths {
+		p, ok := rp.([]interface{})
+		if !ok || len(p) == 0 {
+			continue
+		}
+		attr := fmt.Sprint(p[0])
+		if !seen[attr] {
+			seen[attr] = true
+			attrs = append(attrs, attr)
+		}
+	}
+	if len(attrs) == 0 {
+		return ""
+	}
+	return "forced by " + strings.Join(attrs, ", ")
+}
+
+// The fields of planState are missing in the plan JSON of the older versions of Terraform, which are treated as a complete and applyable plan.
+type planState struct {
+	Errored   bool  `json:"errored"`
+	Complete  *bool `json:"complete"`
+	Applyable *bool `json:"applyable"`
+	// Hash is the SHA-256 of the plan JSON.
+	Hash string `json:"-"`
+}
+
+// Terraform doesn't mark the plan without any changes applyable, so that it isn't a problem by itself.
+func (s *planState) problem(cs *ChangeSummary) string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Errored:
+		return "The plan errored, so the changes are partial and cannot be applied"
+	case s.Applyable != nil && !*s.Applyable && cs.hasChanges():
+		return "The plan cannot be applied"
+	case s.Complete != nil && !*s.Complete:
+		return "The plan is incomplete, so another plan and apply will be needed after this one to converge"
+	default:
+		return ""
+	}
+}
+
+func (s *planState) failed(cs *ChangeSummary) bool {
+	return s != nil && (s.Errored || (s.Applyable != nil && !*s.Applyable && cs.hasChanges()))
+}
+
+func (s *planState) markdown(cs *ChangeSummary) string {
+	problem := s.problem(cs)
+	if problem == "" {
+		return ""
+	}
+
+	kind := "WARNING"
+	if s.failed(cs) {
+		kind = "CAUTION"
 	}
+	return fmt.Sprintf("> [!%s]\n> %s. Please check the run on TFC/E before reviewing the changes.\n", kind, problem)
 }
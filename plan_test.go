@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+func TestUnmarshalActions(t *testing.T) {
+	tests := []struct {
+		actions tfjson.Actions
+		want    Action
+	}{
+		{actions: tfjson.Actions{"no-op"}, want: NoOp},
+		{actions: tfjson.Actions{"create"}, want: Create},
+		{actions: tfjson.Actions{"read"}, want: Read},
+		{actions: tfjson.Actions{"update"}, want: Update},
+		{actions: tfjson.Actions{"delete"}, want: Delete},
+		{actions: tfjson.Actions{"delete", "create"}, want: DeleteThenCreate},
+		{actions: tfjson.Actions{"create", "delete"}, want: CreateThenDelete},
+		{actions: tfjson.Actions{"forget"}, want: Unknown},
+		{actions: tfjson.Actions{"create", "update"}, want: Unknown},
+		{actions: tfjson.Actions{"archive"}, want: Unknown},
+		{actions: nil, want: Unknown},
+	}
+	for _, tt := range tests {
+		if got := UnmarshalActions(tt.actions); got != tt.want {
+			t.Errorf("UnmarshalActions(%v) = %q, want %q", tt.actions, got.String(), tt.want.String())
+		}
+	}
+}
+
+func TestRenderResourceChangeUnknownAction(t *testing.T) {
+	c := &tfjson.ResourceChange{
+		Address: "null_resource.a",
+		Mode:    tfjson.ManagedResourceMode,
+		Type:    "null_resource",
+		Name:    "a",
+		Change:  &tfjson.Change{Actions: tfjson.Actions{"archive"}, Before: map[string]interface{}{"id": "1"}},
+	}
+	got := renderResourceChange(c, UnmarshalActions(c.Change.Actions))
+	if want := "# null_resource.a has an unsupported change\n    # (actions: archive)"; !strings.Contains(got, want) {
+		t.Errorf("got %q, want it to contain %q", got, want)
+	}
+}
+
+func TestPlanState(t *testing.T) {
+	yes, no := true, false
+	changes, noChanges := &ChangeSummary{Add: 1}, &ChangeSummary{}
+
+	tests := []struct {
+		name    string
+		state   *planState
+		cs      *ChangeSummary
+		failed  bool
+		problem string
+		alert   string
+	}{
+		{name: "unknown", cs: changes},
+		{name: "older Terraform", state: &planState{}, cs: changes},
+		{name: "applyable", state: &planState{Complete: &yes, Applyable: &yes}, cs: changes},
+		{
+			name:    "errored",
+			state:   &planState{Errored: true, Complete: &yes, Applyable: &yes},
+			cs:      changes,
+			failed:  true,
+			problem: "The plan errored, so the changes are partial and cannot be applied",
+			alert:   "> [!CAUTION]",
+		},
+		{
+			name:    "not applyable",
+			state:   &planState{Complete: &yes, Applyable: &no},
+			cs:      changes,
+			failed:  true,
+			problem: "The plan cannot be applied",
+			alert:   "> [!CAUTION]",
+		},
+		// Terraform doesn't mark the plan without any changes applyable.
+		{name: "no changes", state: &planState{Complete: &yes, Applyable: &no}, cs: noChanges},
+		{
+			name:    "incomplete",
+			state:   &planState{Complete: &no, Applyable: &yes},
+			cs:      changes,
+			problem: "The plan is incomplete, so another plan and apply will be needed after this one to converge",
+			alert:   "> [!WARNING]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.failed(tt.cs); got != tt.failed {
+				t.Errorf("failed = %v, want %v", got, tt.failed)
+			}
+			if got := tt.state.problem(tt.cs); got != tt.problem {
+				t.Errorf("problem = %q, want %q", got, tt.problem)
+			}
+			if got := tt.state.markdown(tt.cs); !strings.HasPrefix(got, tt.alert) || (tt.alert == "") != (got == "") {
+				t.Errorf("markdown = %q, want the %q alert", got, tt.alert)
+			}
+		})
+	}
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// planRenderer puts the action symbols at the head of lines to be highlighted in a `diff` code block.
+// Without the provider schemas, a list of objects is rendered as nested blocks.
+type planRenderer struct {
+	b             strings.Builder
+	replacePaths  []interface{}
+	relevantPaths []interface{}
+	// relevant tells any attribute at the relevant paths has been rendered.
+	relevant bool
+}
+
+const (
+	symbolCreate   = "+"
+	symbolDelete   = "-"
+	symbolUpdate   = "~"
+	symbolNoChange = " "
+)
+
+func renderResourceChange(c *tfjson.ResourceChange, action Action) string {
+	r := &planRenderer{replacePaths: c.Change.ReplacePaths}
+	if action == NoOp && isMoved(c) && c.Change.Importing == nil {
+		return r.renderResource(c, action.Symbol(), fmt.Sprintf("# %s has moved to %s", c.PreviousAddress, c.Address))
+	}
+
+	description, note := describeResourceChange(c, action)
+	comments := []string{fmt.Sprintf("# %s %s", c.Address, description)}
+	if note != "" {
+		comments = append(comments, "# "+note)
+	}
+	if isMoved(c) {
+		comments = append(comments, fmt.Sprintf("# (moved from %s)", c.PreviousAddress))
+	}
+	if c.Change.Importing != nil {
+		comments = append(comments, fmt.Sprintf("# (imported from %q)", importID(c)))
+	}
+	if c.Change.GeneratedConfig != "" {
+		comments = append(comments, "# (config will be generated)")
+	}
+	return r.renderResource(c, action.Symbol(), comments...)
+}
+
+// renderResourceDrift tells whether any attribute at the relevant paths is marked.
+func renderResourceDrift(c *tfjson.ResourceChange, relevantPaths []interface{}) (string, bool) {
+	action := UnmarshalActions(c.Change.Actions)
+	description := "has changed"
+	if action == Delete {
+		description = "has been deleted"
+	}
+
+	r := &planRenderer{relevantPaths: relevantPaths}
+	return r.renderResource(c, action.Symbol(), fmt.Sprintf("# %s %s", c.Address, description)), r.relevant
+}
+
+func (r *planRenderer) renderResource(c *tfjson.ResourceChange, symbol string, comments ...string) string {
+	mode := "resource"
+	if c.Mode == tfjson.DataResourceMode {
+		mode = "data"
+	}
+
+	var (
+		before = maskSensitiveValues(c.Change.Before, c.Change.BeforeSensitive)
+		after  = mergeUnknownValues(maskSensitiveValues(c.Change.After, c.Change.AfterSensitive), c.Change.AfterUnknown)
+	)
+	beforeObj, _ := before.(map[string]interface{})
+	afterObj, _ := after.(map[string]interface{})
+
+	for _, comment := range comments {
+		r.line(symbolNoChange, 0, comment)
+	}
+	r.line(symbol, 0, fmt.Sprintf("%s %q %q {", mode, c.Type, c.Name))
+	r.writeObject(1, beforeObj, afterObj, nil)
+	r.line(symbolNoChange, 0, "}")
+	return strings.TrimSuffix(r.b.String(), "\n")
+}
+
+func renderOutputChange(name string, c *tfjson.Change) string {
+	r := &planRenderer{}
+	r.writeAttribute(0, name, len(name),
+		maskSensitiveValues(c.Before, c.BeforeSensitive),
+		mergeUnknownValues(maskSensitiveValues(c.After, c.AfterSensitive), c.AfterUnknown),
+		nil,
+	)
+	return strings.TrimSuffix(r.b.String(), "\n")
+}
+
+// unknownValue is the value known only after apply.
+type unknownValue struct{}
+
+// mergeUnknownValues returns a copy of the value replacing the parts known only after apply with unknownValue.
+func mergeUnknownValues(value, unknown interface{}) interface{} {
+	switch u := unknown.(type) {
+	case bool:
+		if u {
+			return unknownValue{}
+		}
+		return value
+	case map[string]interface{}:
+		v, ok := value.(map[string]interface{})
+		if (!ok && value != nil) || (value == nil && !hasMarks(u)) {
+			return value
+		}
+		merged := make(map[string]interface{}, len(v)+len(u))
+		for k, e := range v {
+			merged[k] = e
+		}
+		for k, e := range u {
+			merged[k] = mergeUnknownValues(merged[k], e)
+		}
+		return merged
+	case []interface{}:
+		v, ok := value.([]interface{})
+		if (!ok && value != nil) || (value == nil && !hasMarks(u)) {
+			return value
+		}
+		merged := make([]interface{}, max(len(v), len(u)))
+		copy(merged, v)
+		for i, e := range u {
+			merged[i] = mergeUnknownValues(merged[i], e)
+		}
+		return merged
+	default:
+		return value
+	}
+}
+
+// hasMarks tells any part of the tree mirroring a value, e.g. after_unknown or after_sensitive, is marked with true.
+func hasMarks(tree interface{}) bool {
+	switch u := tree.(type) {
+	case bool:
+		return u
+	case map[string]interface{}:
+		for _, e := range u {
+			if hasMarks(e) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, e := range u {
+			if hasMarks(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *planRenderer) line(symbol string, depth int, text string) {
+	fmt.Fprintf(&r.b, "%-4s%s%s\n", symbol, strings.Repeat("  ", depth), text)
+}
+
+func (r *planRenderer) annotation(path []interface{}) string {
+	if matchPaths(r.replacePaths, path, false) {
+		return " # forces replacement"
+	}
+	// The attribute is marked when it contains or is contained by the relevant one.
+	if matchPaths(r.relevantPaths, path, true) {
+		r.relevant = true
+		return " # affects the planned changes"
+	}
+	return ""
+}
+
+// With prefix, either of the paths can be a prefix of the other.
+func matchPaths(paths []interface{}, path []interface{}, prefix bool) bool {
+	for _, rp := range paths {
+		p, ok := rp.([]interface{})
+		if !ok || len(p) == 0 || (!prefix && len(p) != len(path)) {
+			continue
+		}
+		matched := true
+		for i := 0; i < len(p) && i < len(path); i++ {
+			if fmt.Sprint(p[i]) != fmt.Sprint(path[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func changeSymbol(before, after interface{}) string {
+	switch {
+	case before == nil:
+		return symbolCreate
+	case after == nil:
+		return symbolDelete
+	case reflect.DeepEqual(before, after):
+		return symbolNoChange
+	default:
+		return symbolUpdate
+	}
+}
+
+func isBlocks(v interface{}) bool {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	for _, e := range list {
+		if _, ok := e.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(maps ...map[string]interface{}) []string {
+	seen := make(map[string]struct{})
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// The unchanged attributes and blocks are hidden when both of before and after exist.
+func (r *planRenderer) writeObject(depth int, before, after map[string]interface{}, path []interface{}) {
+	var (
+		updating       = before != nil && after != nil
+		keys           = sortedKeys(before, after)
+		attrs          []string
+		blocks         []string
+		width          int
+		unchangedAttrs int
+	)
+	for _, k := range keys {
+		b, a := before[k], after[k]
+		// The blocks known only after apply or sensitive are rendered as an attribute.
+		if (isBlocks(b) || isBlocks(a)) && !isOpaque(b) && !isOpaque(a) {
+			blocks = append(blocks, k)
+			continue
+		}
+		if b == nil && a == nil {
+			continue
+		}
+		if updating && reflect.DeepEqual(b, a) {
+			unchangedAttrs++
+			continue
+		}
+		attrs = append(attrs, k)
+		if len(k) > width {
+			width = len(k)
+		}
+	}
+
+	for _, k := range attrs {
+		r.writeAttribute(depth, k, width, before[k], after[k], childPath(path, k))
+	}
+	if unchangedAttrs > 0 {
+		r.line(symbolNoChange, depth, fmt.Sprintf("# (%d unchanged %s hidden)", unchangedAttrs, plural(unchangedAttrs, "attribute")))
+	}
+
+	var unchangedBlocks int
+	for _, k := range blocks {
+		bs, _ := before[k].([]interface{})
+		as, _ := after[k].([]interface{})
+		unchangedBlocks += r.writeBlocks(depth, k, bs, as, childPath(path, k), updating)
+	}
+	if unchangedBlocks > 0 {
+		r.line(symbolNoChange, depth, fmt.Sprintf("# (%d unchanged %s hidden)", unchangedBlocks, plural(unchangedBlocks, "block")))
+	}
+}
+
+func (r *planRenderer) writeBlocks(depth int, name string, before, after []interface{}, path []interface{}, updating bool) int {
+	var unchanged int
+	for i := 0; i < len(before) || i < len(after); i++ {
+		var b, a map[string]interface{}
+		if i < len(before) {
+			b, _ = before[i].(map[string]interface{})
+		}
+		if i < len(after) {
+			a, _ = after[i].(map[string]interface{})
+		}
+
+		symbol := changeSymbol(nilIfEmpty(b), nilIfEmpty(a))
+		if symbol == symbolNoChange && updating {
+			unchanged++
+			continue
+		}
+
+		r.line(symbol, depth, name+" {"+r.annotation(childPath(path, i)))
+		r.writeObject(depth+1, b, a, childPath(path, i))
+		r.line(symbolNoChange, depth, "}")
+	}
+	return unchanged
+}
+
+// childPath returns a new path so that the paths of siblings don't share the backing array.
+func childPath(path []interface{}, step interface{}) []interface{} {
+	p := make([]interface{}, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, step)
+}
+
+func nilIfEmpty(m map[string]interface{}) interface{} {
+	if m == nil {
+		return nil
+	}
+	return m
+}
+
+func (r *planRenderer) writeAttribute(depth int, name string, width int, before, after interface{}, path []interface{}) {
+	symbol := changeSymbol(before, after)
+	suffix := r.annotation(path)
+	prefix := fmt.Sprintf("%-*s = ", width, name)
+
+	bm, bIsMap := before.(map[string]interface{})
+	am, aIsMap := after.(map[string]interface{})
+	bl, bIsList := before.([]interface{})
+	al, aIsList := after.([]interface{})
+
+	switch {
+	case (bIsMap || before == nil) && (aIsMap || after == nil) && (bIsMap || aIsMap):
+		if len(bm) == 0 && len(am) == 0 {
+			r.writePrimitive(depth, symbol, prefix, "{}", "{}", suffix)
+			return
+		}
+		r.line(symbol, depth, prefix+"{"+suffix)
+		r.writeMap(depth+1, bm, am, path, bIsMap && aIsMap)
+		r.line(symbolNoChange, depth, "}"+nullSuffix(symbol))
+	case (bIsList || before == nil) && (aIsList || after == nil) && (bIsList || aIsList):
+		if len(bl) == 0 && len(al) == 0 {
+			r.writePrimitive(depth, symbol, prefix, "[]", "[]", suffix)
+			return
+		}
+		r.line(symbol, depth, prefix+"["+suffix)
+		r.writeList(depth+1, bl, al, path, bIsList && aIsList)
+		r.line(symbolNoChange, depth, "]"+nullSuffix(symbol))
+	default:
+		r.writePrimitive(depth, symbol, prefix, formatValue(before), formatValue(after), suffix)
+	}
+}
+
+func (r *planRenderer) writePrimitive(depth int, symbol, prefix, before, after, suffix string) {
+	switch symbol {
+	case symbolCreate:
+		r.line(symbol, depth, prefix+after+suffix)
+	case symbolDelete:
+		r.line(symbol, depth, prefix+before+" -> null"+suffix)
+	case symbolNoChange:
+		r.line(symbol, depth, prefix+after+suffix)
+	default:
+		r.line(symbol, depth, prefix+before+" -> "+after+suffix)
+	}
+}
+
+func nullSuffix(symbol string) string {
+	if symbol == symbolDelete {
+		return " -> null"
+	}
+	return ""
+}
+
+func (r *planRenderer) writeMap(depth int, before, after map[string]interface{}, path []interface{}, updating bool) {
+	var (
+		keys      []string
+		width     int
+		unchanged int
+	)
+	for _, k := range sortedKeys(before, after) {
+		if updating && reflect.DeepEqual(before[k], after[k]) {
+			unchanged++
+			continue
+		}
+		keys = append(keys, k)
+		if n := len(strconv.Quote(k)); n > width {
+			width = n
+		}
+	}
+
+	for _, k := range keys {
+		r.writeAttribute(depth, strconv.Quote(k), width, before[k], after[k], childPath(path, k))
+	}
+	if unchanged > 0 {
+		r.line(symbolNoChange, depth, fmt.Sprintf("# (%d unchanged %s hidden)", unchanged, plural(unchanged, "element")))
+	}
+}
+
+func (r *planRenderer) writeList(depth int, before, after []interface{}, path []interface{}, updating bool) {
+	var unchanged int
+	for _, op := range diffLists(before, after) {
+		if updating && op.hasBefore && op.hasAfter && reflect.DeepEqual(op.before, op.after) {
+			unchanged++
+			continue
+		}
+		r.writeElement(depth, op.before, op.after, childPath(path, op.index))
+	}
+	if unchanged > 0 {
+		r.line(symbolNoChange, depth, fmt.Sprintf("# (%d unchanged %s hidden)", unchanged, plural(unchanged, "element")))
+	}
+}
+
+func (r *planRenderer) writeElement(depth int, before, after interface{}, path []interface{}) {
+	symbol := changeSymbol(before, after)
+
+	bm, bIsMap := before.(map[string]interface{})
+	am, aIsMap := after.(map[string]interface{})
+	bl, bIsList := before.([]interface{})
+	al, aIsList := after.([]interface{})
+
+	// The element changed from or to the masked or unknown one is rendered as a whole.
+	switch {
+	case (bIsMap || before == nil) && (aIsMap || after == nil) && (bIsMap || aIsMap):
+		r.line(symbol, depth, "{")
+		r.writeMap(depth+1, bm, am, path, bIsMap && aIsMap)
+		r.line(symbolNoChange, depth, "},")
+	case (bIsList || before == nil) && (aIsList || after == nil) && (bIsList || aIsList):
+		r.line(symbol, depth, "[")
+		r.writeList(depth+1, bl, al, path, bIsList && aIsList)
+		r.line(symbolNoChange, depth, "],")
+	case symbol == symbolDelete:
+		r.line(symbol, depth, formatValue(before)+",")
+	case symbol == symbolUpdate:
+		r.line(symbol, depth, formatValue(before)+" -> "+formatValue(after)+",")
+	default:
+		r.line(symbol, depth, formatValue(after)+",")
+	}
+}
+
+// listOp is an element of the list diff. The element is removed without after,
+// added without before, and kept or modified in place with both of them.
+type listOp struct {
+	index     int
+	before    interface{}
+	after     interface{}
+	hasBefore bool
+	hasAfter  bool
+}
+
+// maxListDiffCells caps the table of the longest common subsequence, which grows by the product of the lengths.
+const maxListDiffCells = 1 << 20
+
+// diffLists compares the lists by the longest common subsequence, so that inserting or removing
+// an element doesn't make the following ones look changed.
+func diffLists(before, after []interface{}) []listOp {
+	// The common prefix and suffix are left out of the table.
+	var prefix, suffix int
+	for prefix < len(before) && prefix < len(after) && reflect.DeepEqual(before[prefix], after[prefix]) {
+		prefix++
+	}
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		reflect.DeepEqual(before[len(before)-1-suffix], after[len(after)-1-suffix]) {
+		suffix++
+	}
+
+	var ops []listOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, listOp{index: i, before: before[i], after: after[i], hasBefore: true, hasAfter: true})
+	}
+	ops = append(ops, diffListsLCS(before[prefix:len(before)-suffix], after[prefix:len(after)-suffix], prefix)...)
+	for k := suffix; k > 0; k-- {
+		i, j := len(before)-k, len(after)-k
+		ops = append(ops, listOp{index: j, before: before[i], after: after[j], hasBefore: true, hasAfter: true})
+	}
+	return ops
+}
+
+// The lists too long for the table are rendered as a replacement.
+func diffListsLCS(before, after []interface{}, offset int) []listOp {
+	n, m := len(before), len(after)
+	var ops []listOp
+	if n*m > maxListDiffCells {
+		for i := range before {
+			ops = append(ops, listOp{index: offset + i, before: before[i], hasBefore: true})
+		}
+		for j := range after {
+			ops = append(ops, listOp{index: offset + j, after: after[j], hasAfter: true})
+		}
+		return ops
+	}
+
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if reflect.DeepEqual(before[i], after[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && reflect.DeepEqual(before[i], after[j]):
+			ops = append(ops, listOp{index: offset + j, before: before[i], after: after[j], hasBefore: true, hasAfter: true})
+			i++
+			j++
+		case i < n && j < m && isComplex(before[i]) && isComplex(after[j]) && lcs[i+1][j+1] == lcs[i][j]:
+			ops = append(ops, listOp{index: offset + j, before: before[i], after: after[j], hasBefore: true, hasAfter: true})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			// The removal is preferred on ties, so that a replaced element is rendered as "- old" followed by "+ new".
+			ops = append(ops, listOp{index: offset + i, before: before[i], hasBefore: true})
+			i++
+		default:
+			ops = append(ops, listOp{index: offset + j, after: after[j], hasAfter: true})
+			j++
+		}
+	}
+	return ops
+}
+
+// isOpaque tells the value is rendered without its content, which cannot be diffed by its structure.
+func isOpaque(v interface{}) bool {
+	switch v.(type) {
+	case unknownValue, sensitiveValue:
+		return true
+	default:
+		return false
+	}
+}
+
+func isComplex(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case unknownValue:
+		return "(known after apply)"
+	case sensitiveValue:
+		return maskedValue
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
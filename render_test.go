@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestRenderResourceChange renders the resource changes in testdata/render/*.json and compares them with the golden files.
+// Run `go test -run TestRenderResourceChange -update` to update the golden files.
+func TestRenderResourceChange(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "render", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no test data")
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var c tfjson.ResourceChange
+			if err := json.Unmarshal(data, &c); err != nil {
+				t.Fatalf("failed to unmarshal %s: %v", path, err)
+			}
+
+			got := renderResourceChange(&c, UnmarshalActions(c.Change.Actions)) + "\n"
+
+			golden := strings.TrimSuffix(path, ".json") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("rendered change mismatch\n--- got\n%s--- want\n%s", got, want)
+			}
+		})
+	}
+}
+
+func formatListOps(ops []listOp) string {
+	var got []string
+	for _, op := range ops {
+		switch {
+		case op.hasBefore && op.hasAfter:
+			got = append(got, fmt.Sprintf(" %d:%v", op.index, op.after))
+		case op.hasBefore:
+			got = append(got, fmt.Sprintf("-%d:%v", op.index, op.before))
+		default:
+			got = append(got, fmt.Sprintf("+%d:%v", op.index, op.after))
+		}
+	}
+	return strings.Join(got, ",")
+}
+
+func TestDiffListsPrefersRemoval(t *testing.T) {
+	ops := diffLists([]interface{}{"a", "old", "c"}, []interface{}{"a", "new", "c"})
+	if got, want := formatListOps(ops), " 0:a,-1:old,+1:new, 2:c"; got != want {
+		t.Errorf("diffLists() = %v, want %v", got, want)
+	}
+}
+
+func TestDiffListsLong(t *testing.T) {
+	list := func(prefix string, n int) []interface{} {
+		l := make([]interface{}, n)
+		for i := range l {
+			l[i] = fmt.Sprintf("%s%d", prefix, i)
+		}
+		return l
+	}
+
+	// The common prefix and suffix are matched without the table.
+	before := list("e", 5000)
+	after := append(append(append([]interface{}{}, before[:2500]...), "new"), before[2500:]...)
+	ops := diffLists(before, after)
+	if len(ops) != 5001 || !ops[2500].hasAfter || ops[2500].hasBefore || ops[2500].index != 2500 {
+		t.Errorf("inserting an element = %d ops, the one at 2500 is %+v", len(ops), ops[2500])
+	}
+
+	// The lists too long to compare are rendered as a replacement.
+	before, after = list("a", 2000), list("b", 2000)
+	before[1000], after[0] = "x", "x"
+	ops = diffLists(before, after)
+	if len(ops) != 4000 {
+		t.Fatalf("replacing the list = %d ops, want 4000", len(ops))
+	}
+	for i, op := range ops {
+		if removal := i < 2000; op.hasBefore != removal || op.hasAfter == removal || op.index != i%2000 {
+			t.Fatalf("ops[%d] = %+v, want all the removals followed by all the additions", i, op)
+		}
+	}
+}
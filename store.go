@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	planStoreFile = "file"
+	planStoreBolt = "bolt"
+)
+
+type planStore interface {
+	put(id string, data []byte) error
+	// get returns errNotFound when the plan doesn't exist.
+	get(id string) ([]byte, error)
+	prune(before time.Time) (int, error)
+	close() error
+}
+
+// The IDs are the run IDs, which are also used as the file names.
+var planIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func newPlanStore(kind, path string) (planStore, error) {
+	switch kind {
+	case planStoreFile:
+		if err := os.MkdirAll(path, 0o700); err != nil {
+			return nil, err
+		}
+		return &filePlanStore{dir: path}, nil
+	case planStoreBolt:
+		db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+		if err != nil {
+			return nil, err
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			if _, err := tx.CreateBucketIfNotExists(boltPlansBucket); err != nil {
+				return err
+			}
+			_, err := tx.CreateBucketIfNotExists(boltStoredAtBucket)
+			return err
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &boltPlanStore{db: db}, nil
+	default:
+		return nil, fmt.Errorf("unsupported plan storage: %s", kind)
+	}
+}
+
+type filePlanStore struct {
+	dir string
+}
+
+func (s *filePlanStore) put(id string, data []byte) error {
+	if !planIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid plan ID: %s", id)
+	}
+
+	// The plan is renamed from a temporary file so that a partially written one is never served.
+	f, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(s.dir, id+".json"))
+}
+
+func (s *filePlanStore) get(id string) ([]byte, error) {
+	if !planIDPattern.MatchString(id) {
+		return nil, errNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errNotFound
+	}
+	return data, err
+}
+
+// The temporary files left by the interrupted writes are pruned as well.
+func (s *filePlanStore) prune(before time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var pruned int
+	for _, e := range entries {
+		name := e.Name()
+		plan := strings.HasSuffix(name, ".json")
+		if e.IsDir() || (!plan && !strings.HasSuffix(name, ".tmp")) {
+			continue
+		}
+		info, err := e.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return pruned, err
+		}
+		if !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return pruned, err
+		}
+		if plan {
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+func (s *filePlanStore) close() error {
+	return nil
+}
+
+var (
+	boltPlansBucket    = []byte("plans")
+	boltStoredAtBucket = []byte("stored_at")
+)
+
+type boltPlanStore struct {
+	db *bolt.DB
+}
+
+func (s *boltPlanStore) put(id string, data []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltPlansBucket).Put([]byte(id), data); err != nil {
+			return err
+		}
+		storedAt := strconv.AppendInt(nil, time.Now().Unix(), 10)
+		return tx.Bucket(boltStoredAtBucket).Put([]byte(id), storedAt)
+	})
+}
+
+func (s *boltPlanStore) get(id string) ([]byte, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltPlansBucket).Get([]byte(id))
+		if v == nil {
+			return errNotFound
+		}
+		// The value is only valid while the transaction is open.
+		data = append([]byte(nil), v...)
+		return nil
+	})
+	return data, err
+}
+
+func (s *boltPlanStore) prune(before time.Time) (int, error) {
+	var pruned int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		plans, storedAt := tx.Bucket(boltPlansBucket), tx.Bucket(boltStoredAtBucket)
+
+		// The bucket must not be modified during the iteration.
+		var ids [][]byte
+		err := plans.ForEach(func(id, _ []byte) error {
+			t, err := strconv.ParseInt(string(storedAt.Get(id)), 10, 64)
+			if err == nil && time.Unix(t, 0).Before(before) {
+				ids = append(ids, append([]byte(nil), id...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := plans.Delete(id); err != nil {
+				return err
+			}
+			if err := storedAt.Delete(id); err != nil {
+				return err
+			}
+		}
+		pruned = len(ids)
+		return nil
+	})
+	return pruned, err
+}
+
+func (s *boltPlanStore) close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// The fields of commentData are documented in the README, so that they must be kept compatible.
+type commentData struct {
+	Run        *commentRun
+	Workspace  *commentWorkspace
+	Summary    *ChangeSummary
+	Resources  []*commentResource
+	Outputs    []*commentOutput
+	Checks     []*commentCheck
+	Guardrails string
+	ViewerURL  string
+}
+
+type commentRun struct {
+	ID             string
+	URL            string
+	Message        string
+	CreatedAt      time.Time
+	CreatedBy      string
+	IsSpeculative  bool
+	Organization   string
+	Branch         string
+	Commit         string
+	CommitURL      string
+	PullRequestURL string
+	RepositoryURL  string
+}
+
+type commentWorkspace struct {
+	ID               string
+	Name             string
+	URL              string
+	WorkingDirectory string
+}
+
+type commentResource struct {
+	Address           string
+	ModuleAddress     string
+	Mode              string
+	Type              string
+	Name              string
+	PreviousAddress   string
+	DeposedKey        string
+	ImportID          string
+	GeneratedConfig   string
+	Action            string
+	Symbol            string
+	Description       string
+	ReplacementReason string
+	Diff              string
+}
+
+type commentOutput struct {
+	Name   string
+	Action string
+	Symbol string
+	Diff   string
+}
+
+type commentCheck struct {
+	Address  string
+	Kind     string
+	Status   string
+	Problems []string
+}
+
+func loadCommentTemplate(path string) (*template.Template, error) {
+	return template.New(filepath.Base(path)).ParseFiles(path)
+}
+
+func makeCommentData(plan *tfjson.Plan, req *TFERunTasksRequest, guardrails *guardrailReport, viewerURL string) *commentData {
+	data := &commentData{
+		Run: &commentRun{
+			ID:             req.RunID,
+			URL:            req.RunAppURL,
+			Message:        req.RunMessage,
+			CreatedAt:      req.RunCreatedAt,
+			CreatedBy:      req.RunCreatedBy,
+			IsSpeculative:  req.IsSpeculative,
+			Organization:   req.OrganizationName,
+			Branch:         req.VCSBranch,
+			Commit:         shortCommit(req.VCSCommitURL),
+			CommitURL:      req.VCSCommitURL,
+			PullRequestURL: req.VCSPullRequestURL,
+			RepositoryURL:  req.VCSRepoURL,
+		},
+		Workspace: &commentWorkspace{
+			ID:               req.WorkspaceID,
+			Name:             req.WorkspaceName,
+			URL:              req.WorkspaceAppURL,
+			WorkingDirectory: req.WorkspaceWorkingDirectory,
+		},
+		Summary:   summarizeChanges(plan),
+		ViewerURL: viewerURL,
+	}
+	if guardrails != nil {
+		data.Guardrails = guardrails.markdown()
+	}
+
+	for _, c := range plan.ResourceChanges {
+		if c.Change == nil {
+			continue
+		}
+
+		action := UnmarshalActions(c.Change.Actions)
+		if action == NoOp && !isMoved(c) && !isImported(c) {
+			continue
+		}
+
+		description, _ := describeResourceChange(c, action)
+		data.Resources = append(data.Resources, &commentResource{
+			Address:           c.Address,
+			ModuleAddress:     c.ModuleAddress,
+			Mode:              string(c.Mode),
+			Type:              c.Type,
+			Name:              c.Name,
+			PreviousAddress:   c.PreviousAddress,
+			DeposedKey:        c.DeposedKey,
+			ImportID:          importID(c),
+			GeneratedConfig:   c.Change.GeneratedConfig,
+			Action:            action.String(),
+			Symbol:            action.Symbol(),
+			Description:       description,
+			ReplacementReason: replacementReason(c, action),
+			Diff:              renderResourceChange(c, action),
+		})
+	}
+
+	names := make([]string, 0, len(plan.OutputChanges))
+	for name := range plan.OutputChanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		output := plan.OutputChanges[name]
+		action := UnmarshalActions(output.Actions)
+		if action == NoOp {
+			continue
+		}
+
+		data.Outputs = append(data.Outputs, &commentOutput{
+			Name:   name,
+			Action: action.String(),
+			Symbol: action.Symbol(),
+			Diff:   renderOutputChange(name, output),
+		})
+	}
+
+	for _, c := range plan.Checks {
+		data.Checks = append(data.Checks, &commentCheck{
+			Address:  c.Address.ToDisplay,
+			Kind:     string(c.Address.Kind),
+			Status:   string(c.Status),
+			Problems: checkProblems(c),
+		})
+	}
+	return data
+}
+
+// The hidden tags and the alert of the errored or incomplete plan are put ahead, so that the template cannot leave them out.
+func makeTemplatedComment(tmpl *template.Template, plan *tfjson.Plan, data *commentData, req *TFERunTasksRequest, state *planState) (string, error) {
+	meta, err := makeCommentMetadata(plan, req, state)
+	if err != nil {
+		return "", fmt.Errorf("failed to make the comment metadata: %w", err)
+	}
+
+	var b strings.Builder
+	writeCommentTags(&b, req, data.Summary.String(), meta)
+	if alert := state.markdown(data.Summary); alert != "" {
+		b.WriteString(alert)
+		b.WriteString("\n")
+	}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -2,20 +2,75 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"sort"
 
 	tfjson "github.com/hashicorp/terraform-json"
 )
 
 // https://developer.hashicorp.com/terraform/internals/json-format#plan-representation
-func parsePlan(ctx context.Context, client *http.Client, url, token string) (*tfjson.Plan, error) {
+func parsePlan(ctx context.Context, client *http.Client, url, token string) (*tfjson.Plan, *planState, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("Unexpected status was returned: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var plan *tfjson.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, nil, err
+	}
+
+	if err := plan.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	// terraform-json doesn't decode all of the fields telling the state of the plan.
+	var state planState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, nil, err
+	}
+	sum := sha256.Sum256(data)
+	state.Hash = "sha256:" + hex.EncodeToString(sum[:])
+
+	return plan, &state, nil
+}
+
+func fetchStateOutputs(ctx context.Context, client *http.Client, apiURL, workspaceID, token string) ([]*TFEStateVersionOutput, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path, u.RawPath, u.RawQuery = "", "", ""
+	u = u.JoinPath("/api/v2/workspaces", workspaceID, "current-state-version-outputs")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/vnd.api+json")
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -27,14 +82,39 @@ func parsePlan(ctx context.Context, client *http.Client, url, token string) (*tf
 		return nil, fmt.Errorf("Unexpected status was returned: %d", resp.StatusCode)
 	}
 
-	var plan *tfjson.Plan
-	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+	var body TFEStateVersionOutputsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
 		return nil, err
 	}
 
-	if err := plan.Validate(); err != nil {
-		return nil, err
+	outputs := make([]*TFEStateVersionOutput, 0, len(body.Data))
+	for _, d := range body.Data {
+		if d.Attributes != nil {
+			outputs = append(outputs, d.Attributes)
+		}
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].Name < outputs[j].Name
+	})
+	return outputs, nil
+}
+
+// plannedOutputs is used when the state is not accessible.
+func plannedOutputs(plan *tfjson.Plan) []*TFEStateVersionOutput {
+	if plan.PlannedValues == nil {
+		return nil
 	}
 
-	return plan, nil
+	outputs := make([]*TFEStateVersionOutput, 0, len(plan.PlannedValues.Outputs))
+	for name, o := range plan.PlannedValues.Outputs {
+		outputs = append(outputs, &TFEStateVersionOutput{
+			Name:      name,
+			Sensitive: o.Sensitive,
+			Value:     o.Value,
+		})
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].Name < outputs[j].Name
+	})
+	return outputs
 }
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vcsComment is the first note of a thread for the providers organizing comments in threads.
+type vcsComment struct {
+	ID       string
+	ThreadID string
+	Body     string
+	Hidden   bool
+	// Version is required by the providers using optimistic locking.
+	Version int
+}
+
+type vcsNewComment struct {
+	Body string
+	// Destructive is reflected in the status of the comment by the providers supporting it.
+	Destructive bool
+}
+
+type vcsProvider interface {
+	parseURL(u *url.URL) (gitURL, error)
+	maxCommentLength() int
+	// listComments returns the comments in the order they were posted.
+	listComments(ctx context.Context, url gitURL) ([]*vcsComment, error)
+	createComment(ctx context.Context, url gitURL, comment *vcsNewComment) error
+	updateComment(ctx context.Context, url gitURL, comment *vcsComment, update *vcsNewComment) error
+	// hideComment minimizes or resolves the outdated comment.
+	hideComment(ctx context.Context, url gitURL, comment *vcsComment) error
+}
+
+var errNotFound = errors.New("not found")
+
+// The comments for the other workspaces triggered by the same pull request are kept.
+func findLatestComments(ctx context.Context, p vcsProvider, url gitURL, workspaceID string) ([]*vcsComment, error) {
+	comments, err := p.listComments(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := filterLatestComment(comments, workspaceID)
+	if comment == nil {
+		return nil, errNotFound
+	}
+	return append([]*vcsComment{comment}, filterContinuedComments(comments, comment)...), nil
+}
+
+var (
+	commentWorkspacePattern = regexp.MustCompile(`<!-- runtasks-pr-comment-workspace: (\S*) `)
+	commentRunPattern       = regexp.MustCompile(`<!-- runtasks-pr-comment-run: (\S*) -->`)
+	commentPagePattern      = regexp.MustCompile(`<!-- runtasks-pr-comment-page: (\d+)/\d+ -->`)
+)
+
+func filterLatestComment(comments []*vcsComment, workspaceID string) *vcsComment {
+	for i := range comments {
+		comment := comments[len(comments)-i-1]
+		if !strings.HasPrefix(comment.Body, commentTag) || isContinuedComment(comment) || isConfigurationComment(comment) {
+			continue
+		}
+
+		// The comments posted before scoped by the workspace don't have the tag.
+		m := commentWorkspacePattern.FindStringSubmatch(comment.Body)
+		if m == nil || m[1] == workspaceID {
+			return comment
+		}
+	}
+	return nil
+}
+
+func isContinuedComment(comment *vcsComment) bool {
+	return commentPagePattern.MatchString(comment.Body)
+}
+
+func isConfigurationComment(comment *vcsComment) bool {
+	return strings.Contains(comment.Body, commentConfigurationTag)
+}
+
+func findConfigurationComments(ctx context.Context, p vcsProvider, url gitURL, workspaceID string) ([]*vcsComment, error) {
+	comments, err := p.listComments(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return filterConfigurationComments(comments, workspaceID), nil
+}
+
+func filterConfigurationComments(comments []*vcsComment, workspaceID string) []*vcsComment {
+	var summaries []*vcsComment
+	for _, comment := range comments {
+		if !strings.HasPrefix(comment.Body, commentTag) || !isConfigurationComment(comment) || comment.Hidden {
+			continue
+		}
+		if m := commentWorkspacePattern.FindStringSubmatch(comment.Body); m != nil && m[1] == workspaceID {
+			summaries = append(summaries, comment)
+		}
+	}
+	return summaries
+}
+
+// The continued comments are tied to the first one by the tags of the workspace and the run.
+func filterContinuedComments(comments []*vcsComment, first *vcsComment) []*vcsComment {
+	workspace := commentWorkspacePattern.FindString(first.Body)
+	run := commentRunPattern.FindString(first.Body)
+	if workspace == "" || run == "" {
+		return nil
+	}
+
+	var continued []*vcsComment
+	for _, comment := range comments {
+		if !strings.HasPrefix(comment.Body, commentTag) || !isContinuedComment(comment) {
+			continue
+		}
+		if strings.Contains(comment.Body, workspace) && strings.Contains(comment.Body, run) {
+			continued = append(continued, comment)
+		}
+	}
+	sort.SliceStable(continued, func(i, j int) bool {
+		return commentPage(continued[i]) < commentPage(continued[j])
+	})
+	return continued
+}
+
+func commentPage(comment *vcsComment) int {
+	m := commentPagePattern.FindStringSubmatch(comment.Body)
+	if m == nil {
+		return 0
+	}
+	page, _ := strconv.Atoi(m[1])
+	return page
+}
+
+func findRunComment(ctx context.Context, p vcsProvider, url gitURL, runID string) (*vcsComment, error) {
+	comments, err := p.listComments(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := filterRunComment(comments, runID)
+	if comment == nil {
+		return nil, errNotFound
+	}
+	return comment, nil
+}
+
+func filterRunComment(comments []*vcsComment, runID string) *vcsComment {
+	tag := fmt.Sprintf(commentRunTag, runID)
+	for i := range comments {
+		comment := comments[len(comments)-i-1]
+		if strings.HasPrefix(comment.Body, commentTag) && strings.Contains(comment.Body, tag) && !isContinuedComment(comment) && !isConfigurationComment(comment) {
+			return comment
+		}
+	}
+	return nil
+}
+
+type apiClient struct {
+	apiURL     string
+	httpClient *http.Client
+	authorize  func(req *http.Request)
+}
+
+func newAPIClient(apiURL string, authorize func(req *http.Request)) *apiClient {
+	return &apiClient{
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		authorize:  authorize,
+	}
+}
+
+func bearerAuth(token string) func(req *http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func basicAuth(username, password string) func(req *http.Request) {
+	return func(req *http.Request) {
+		req.SetBasicAuth(username, password)
+	}
+}
+
+func (c *apiClient) do(ctx context.Context, method, url string, in, out interface{}) (*http.Response, error) {
+	req, err := newJSONRequest(ctx, method, url, in)
+	if err != nil {
+		return nil, err
+	}
+	c.authorize(req)
+	return doJSON(c.httpClient, req, out)
+}
+
+func newJSONRequest(ctx context.Context, method, url string, in interface{}) (*http.Request, error) {
+	var body io.Reader
+	if in != nil {
+		buf := &bytes.Buffer{}
+		if err := json.NewEncoder(buf).Encode(in); err != nil {
+			return nil, err
+		}
+		body = buf
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("Unexpected status was returned: %d", e.status)
+}
+
+func doJSON(client *http.Client, req *http.Request, out interface{}) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &statusError{status: resp.StatusCode}
+	}
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return resp, err
+	}
+	return resp, json.NewDecoder(resp.Body).Decode(out)
+}
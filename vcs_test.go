@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterContinuedComments(t *testing.T) {
+	continued := func(id string, req *TFERunTasksRequest, page, total int) *vcsComment {
+		var b strings.Builder
+		writeContinuedCommentHeader(&b, req, page, total)
+		return &vcsComment{ID: id, Body: b.String()}
+	}
+	run := &TFERunTasksRequest{RunID: "run-1", WorkspaceID: "ws-1", WorkspaceName: "production"}
+	var b strings.Builder
+	writeCommentTags(&b, run, "summary", "")
+	first := &vcsComment{ID: "first", Body: b.String()}
+
+	comments := []*vcsComment{
+		first,
+		continued("page3", run, 3, 3),
+		continued("other-run", &TFERunTasksRequest{RunID: "run-2", WorkspaceID: "ws-1", WorkspaceName: "production"}, 2, 2),
+		continued("other-workspace", &TFERunTasksRequest{RunID: "run-1", WorkspaceID: "ws-2", WorkspaceName: "staging"}, 2, 2),
+		{ID: "untagged", Body: "<!-- runtasks-pr-comment-run: run-1 -->\n<!-- runtasks-pr-comment-page: 2/3 -->"},
+		continued("page2", run, 2, 3),
+	}
+
+	tests := []struct {
+		name  string
+		first *vcsComment
+		want  []string
+	}{
+		{name: "pages of the run", first: first, want: []string{"page2", "page3"}},
+		{name: "first without tags", first: &vcsComment{ID: "plain", Body: "comment"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range filterContinuedComments(comments, tt.first) {
+				got = append(got, c.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("comments = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterConfigurationComments(t *testing.T) {
+	run := &TFERunTasksRequest{RunID: "run-1", WorkspaceID: "ws-1", WorkspaceName: "production"}
+	plan := func(id string) *vcsComment {
+		var b strings.Builder
+		writeCommentTags(&b, run, "summary", "")
+		return &vcsComment{ID: id, Body: b.String()}
+	}
+	summary := func(id string, req *TFERunTasksRequest, hidden bool) *vcsComment {
+		return &vcsComment{ID: id, Body: makeConfigurationComment(&configSummary{}, req), Hidden: hidden}
+	}
+	comments := []*vcsComment{
+		plan("plan"),
+		summary("hidden", run, true),
+		summary("other-workspace", &TFERunTasksRequest{RunID: "run-2", WorkspaceID: "ws-2"}, false),
+		summary("summary", run, false),
+	}
+
+	var got []string
+	for _, c := range filterConfigurationComments(comments, "ws-1") {
+		got = append(got, c.ID)
+	}
+	if strings.Join(got, ",") != "summary" {
+		t.Errorf("summaries = %v, want [summary]", got)
+	}
+
+	// The summary is never edited nor hidden as the plan comment of the run.
+	if c := filterLatestComment(comments, "ws-1"); c == nil || c.ID != "plan" {
+		t.Errorf("latest comment = %+v, want the plan", c)
+	}
+	if c := filterRunComment(comments, "run-1"); c == nil || c.ID != "plan" {
+		t.Errorf("run comment = %+v, want the plan", c)
+	}
+}
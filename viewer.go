@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const planViewerPath = "/plans/"
+
+// The plans are only accessible through the links signed by the secret, which expire after the TTL.
+type planViewer struct {
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+	store   planStore
+}
+
+// The sensitive values are masked before planView is stored.
+type planView struct {
+	RunID         string            `json:"run_id"`
+	RunURL        string            `json:"run_url"`
+	WorkspaceName string            `json:"workspace_name"`
+	CommitURL     string            `json:"commit_url"`
+	Summary       string            `json:"summary"`
+	Problem       string            `json:"problem,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Modules       []*planViewModule `json:"modules"`
+	Outputs       string            `json:"outputs,omitempty"`
+}
+
+type planViewModule struct {
+	Address   string              `json:"address"`
+	Resources []*planViewResource `json:"resources"`
+}
+
+type planViewResource struct {
+	Address         string `json:"address"`
+	Action          string `json:"action"`
+	Diff            string `json:"diff"`
+	GeneratedConfig string `json:"generated_config,omitempty"`
+}
+
+func makePlanView(plan *tfjson.Plan, state *planState, req *TFERunTasksRequest, createdAt time.Time) *planView {
+	cs := summarizeChanges(plan)
+	view := &planView{
+		RunID:         req.RunID,
+		RunURL:        req.RunAppURL,
+		WorkspaceName: req.WorkspaceName,
+		CommitURL:     req.VCSCommitURL,
+		Summary:       cs.String(),
+		Problem:       state.problem(cs),
+		CreatedAt:     createdAt,
+	}
+
+	modules := make(map[string]*planViewModule)
+	for _, c := range plan.ResourceChanges {
+		if c.Change == nil {
+			continue
+		}
+
+		action := UnmarshalActions(c.Change.Actions)
+		if action == NoOp && !isMoved(c) && !isImported(c) {
+			continue
+		}
+
+		m, ok := modules[c.ModuleAddress]
+		if !ok {
+			m = &planViewModule{Address: c.ModuleAddress}
+			modules[c.ModuleAddress] = m
+			view.Modules = append(view.Modules, m)
+		}
+		m.Resources = append(m.Resources, &planViewResource{
+			Address:         c.Address,
+			Action:          action.String(),
+			Diff:            renderResourceChange(c, action),
+			GeneratedConfig: c.Change.GeneratedConfig,
+		})
+	}
+	sort.SliceStable(view.Modules, func(i, j int) bool {
+		return view.Modules[i].Address < view.Modules[j].Address
+	})
+
+	view.Outputs, _ = renderOutputChanges(plan.OutputChanges)
+	return view
+}
+
+// The link works once the plan created at the time is saved.
+func (v *planViewer) link(runID string, createdAt time.Time) string {
+	return v.signedURL(runID, createdAt.Add(v.ttl))
+}
+
+func (v *planViewer) save(view *planView) error {
+	data, err := json.Marshal(view)
+	if err != nil {
+		return err
+	}
+	return v.store.put(view.RunID, data)
+}
+
+func (v *planViewer) prune(now time.Time) {
+	n, err := v.store.prune(now.Add(-v.ttl))
+	if err != nil {
+		log.Printf("Failed to prune the expired plans: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Pruned %d expired %s", n, plural(n, "plan"))
+	}
+}
+
+func (v *planViewer) pruneEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	v.prune(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			v.prune(now)
+		}
+	}
+}
+
+func (v *planViewer) signedURL(id string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {v.signature(id, expires)},
+	}
+	return fmt.Sprintf("%s%s%s?%s", strings.TrimSuffix(v.baseURL, "/"), planViewerPath, url.PathEscape(id), query.Encode())
+}
+
+func (v *planViewer) signature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, v.secret)
+	fmt.Fprintf(mac, "%s\n%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (v *planViewer) handlePlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, planViewerPath)
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid link", http.StatusForbidden)
+		return
+	}
+	signature := r.URL.Query().Get("signature")
+	if !hmac.Equal([]byte(signature), []byte(v.signature(id, expires))) {
+		http.Error(w, "invalid link", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > expires {
+		http.Error(w, "link expired", http.StatusForbidden)
+		return
+	}
+
+	data, err := v.store.get(id)
+	if errors.Is(err, errNotFound) {
+		http.Error(w, "plan not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load the plan %s: %v", id, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var view planView
+	if err := json.Unmarshal(data, &view); err != nil {
+		log.Printf("Failed to unmarshal the plan %s: %v", id, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The link carries the signature, so that it must not be leaked through the referrer.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; script-src 'unsafe-inline'")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := planViewTemplate.Execute(w, &view); err != nil {
+		log.Printf("Failed to render the plan %s: %v", id, err)
+	}
+}
+
+type diffLine struct {
+	Class string
+	Text  string
+}
+
+func diffLines(diff string) []*diffLine {
+	lines := strings.Split(diff, "\n")
+	result := make([]*diffLine, 0, len(lines))
+	for _, line := range lines {
+		class := ""
+		switch {
+		case strings.HasPrefix(line, "-/+"), strings.HasPrefix(line, "+/-"):
+			class = "replace"
+		case strings.HasPrefix(line, symbolCreate):
+			class = "create"
+		case strings.HasPrefix(line, symbolDelete):
+			class = "delete"
+		case strings.HasPrefix(line, symbolUpdate):
+			class = "update"
+		}
+		result = append(result, &diffLine{Class: class, Text: line})
+	}
+	return result
+}
+
+var planViewTemplate = template.Must(template.New("plan").Funcs(template.FuncMap{
+	"diffLines": diffLines,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.WorkspaceName}} {{.RunID}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+summary { cursor: pointer; padding: 2px 0; }
+details details { margin-left: 1.5em; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; }
+code, pre { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 13px; }
+#search { width: 100%; max-width: 40em; padding: 6px; margin-bottom: 1em; }
+.create { color: #1a7f37; }
+.delete { color: #cf222e; }
+.update { color: #9a6700; }
+.replace { color: #8250df; }
+.hidden { display: none; }
+.problem { border-left: 4px solid #cf222e; padding: 0.5em 1em; background: #ffebe9; }
+</style>
+</head>
+<body>
+<h1>Terraform Cloud/Enterprise Plan Output</h1>
+<p>
+{{- if .WorkspaceName}}<strong>Workspace:</strong> {{.WorkspaceName}} · {{end -}}
+<strong>Run:</strong> <a href="{{.RunURL}}">{{.RunID}}</a> · <strong>Commit:</strong> <a href="{{.CommitURL}}">{{.CommitURL}}</a>
+</p>
+{{- if .Problem}}
+<p class="problem"><strong>{{.Problem}}.</strong></p>
+{{- end}}
+<pre>{{.Summary}}</pre>
+<input id="search" type="search" placeholder="Filter resources by address">
+{{- range .Modules}}
+<details class="module" open>
+<summary><strong>{{if .Address}}{{.Address}}{{else}}Root module{{end}}</strong> ({{len .Resources}})</summary>
+{{- range .Resources}}
+<details class="resource" data-address="{{.Address}}">
+<summary class="{{.Action}}"><code>{{.Address}}</code></summary>
+<pre>{{range diffLines .Diff}}<span class="{{.Class}}">{{.Text}}</span>
+{{end}}</pre>
+{{- if .GeneratedConfig}}
+<details>
+<summary>Generated configuration</summary>
+<pre>{{.GeneratedConfig}}</pre>
+</details>
+{{- end}}
+</details>
+{{- end}}
+</details>
+{{- end}}
+{{- if .Outputs}}
+<details class="module" open>
+<summary><strong>Outputs</strong></summary>
+<pre>{{range diffLines .Outputs}}<span class="{{.Class}}">{{.Text}}</span>
+{{end}}</pre>
+</details>
+{{- end}}
+<script>
+document.getElementById("search").addEventListener("input", function (e) {
+  var query = e.target.value.toLowerCase();
+  document.querySelectorAll("details.module").forEach(function (module) {
+    var matched = 0;
+    module.querySelectorAll("details.resource").forEach(function (resource) {
+      var hit = resource.dataset.address.toLowerCase().indexOf(query) >= 0;
+      resource.classList.toggle("hidden", !hit);
+      if (hit) matched++;
+    });
+    if (module.querySelector("details.resource")) {
+      module.classList.toggle("hidden", matched === 0);
+    }
+  });
+});
+</script>
+</body>
+</html>
+`))
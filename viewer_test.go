@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestPlanViewer(t *testing.T) *planViewer {
+	store, err := newPlanStore(planStoreFile, t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open the store: %v", err)
+	}
+	t.Cleanup(func() { store.close() })
+
+	return &planViewer{
+		baseURL: "https://runtasks.example.com/",
+		secret:  []byte("secret"),
+		ttl:     time.Hour,
+		store:   store,
+	}
+}
+
+func TestPlanViewerSignedURL(t *testing.T) {
+	v := newTestPlanViewer(t)
+	expiresAt := time.Unix(1700000000, 0)
+
+	u, err := url.Parse(v.signedURL("run-1", expiresAt))
+	if err != nil {
+		t.Fatalf("failed to parse the link: %v", err)
+	}
+	if u.Host != "runtasks.example.com" || u.Path != "/plans/run-1" {
+		t.Errorf("link = %s, want the one to /plans/run-1", u)
+	}
+	if got := u.Query().Get("expires"); got != "1700000000" {
+		t.Errorf("expires = %s, want 1700000000", got)
+	}
+	if got, want := u.Query().Get("signature"), v.signature("run-1", 1700000000); got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+
+	// The ID is escaped as a path segment.
+	u, err = url.Parse(v.signedURL("run 1/../x", expiresAt))
+	if err != nil {
+		t.Fatalf("failed to parse the link: %v", err)
+	}
+	if got := u.EscapedPath(); got != "/plans/run%201%2F..%2Fx" {
+		t.Errorf("escaped path = %s", got)
+	}
+}
+
+func TestPlanViewerHandlePlan(t *testing.T) {
+	v := newTestPlanViewer(t)
+	now := time.Now()
+	if err := v.save(&planView{RunID: "run-1", Summary: "+ 1 to add, ~ 0 to change, - 0 to destroy.", CreatedAt: now}); err != nil {
+		t.Fatalf("failed to save the plan: %v", err)
+	}
+
+	valid := v.link("run-1", now)
+	tampered := func(key, value string) string {
+		u, _ := url.Parse(valid)
+		q := u.Query()
+		q.Set(key, value)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{name: "valid", url: valid, status: http.StatusOK},
+		{name: "expired", url: v.signedURL("run-1", now.Add(-time.Minute)), status: http.StatusForbidden},
+		{name: "tampered signature", url: tampered("signature", strings.Repeat("0", 64)), status: http.StatusForbidden},
+		{name: "tampered expiry", url: tampered("expires", strconv.FormatInt(now.Add(24*time.Hour).Unix(), 10)), status: http.StatusForbidden},
+		{name: "invalid expiry", url: tampered("expires", "tomorrow"), status: http.StatusForbidden},
+		{name: "tampered ID", url: strings.Replace(valid, "/plans/run-1", "/plans/run-2", 1), status: http.StatusForbidden},
+		{name: "not found", url: v.link("run-2", now), status: http.StatusNotFound},
+		// The escaped ID is verified as it is decoded, and never reaches outside of the store.
+		{name: "escaped ID", url: v.link("../run-1", now), status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			v.handlePlan(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK && !strings.Contains(rec.Body.String(), "1 to add") {
+				t.Errorf("the plan is not rendered: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestPlanStorePrune(t *testing.T) {
+	for _, kind := range []string{planStoreFile, planStoreBolt} {
+		t.Run(kind, func(t *testing.T) {
+			path := t.TempDir()
+			if kind == planStoreBolt {
+				path = filepath.Join(path, "plans.db")
+			}
+			store, err := newPlanStore(kind, path)
+			if err != nil {
+				t.Fatalf("failed to open the store: %v", err)
+			}
+			defer store.close()
+
+			if err := store.put("run-1", []byte("{}")); err != nil {
+				t.Fatalf("failed to put the plan: %v", err)
+			}
+
+			if n, err := store.prune(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+				t.Errorf("prune() = %d, %v, want nothing pruned", n, err)
+			}
+			if _, err := store.get("run-1"); err != nil {
+				t.Errorf("the plan not expired is pruned: %v", err)
+			}
+
+			if n, err := store.prune(time.Now().Add(time.Hour)); err != nil || n != 1 {
+				t.Errorf("prune() = %d, %v, want 1 pruned", n, err)
+			}
+			if _, err := store.get("run-1"); err != errNotFound {
+				t.Errorf("get() = %v, want %v", err, errNotFound)
+			}
+		})
+	}
+}
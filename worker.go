@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+var (
+	errQueueFull  = errors.New("queue is full")
+	errPoolClosed = errors.New("pool is closed")
+)
+
+type job struct {
+	name     string
+	deadline time.Time
+	run      func(ctx context.Context) error
+	// recovered is called after run panics. It can be nil.
+	recovered func(ctx context.Context)
+}
+
+type workerPool struct {
+	jobs chan *job
+	wg   sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newWorkerPool(workers, queueSize int) *workerPool {
+	p := &workerPool{
+		jobs: make(chan *job, queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	defer p.wg.Done()
+
+	for j := range p.jobs {
+		p.runJob(j)
+	}
+}
+
+// A panicking job must not take down the server with the queued ones.
+func (p *workerPool) runJob(j *job) {
+	ctx, cancel := context.WithDeadline(context.Background(), j.deadline)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panicked processing %s: %v\n%s", j.name, r, debug.Stack())
+			if j.recovered != nil {
+				j.recovered(ctx)
+			}
+		}
+	}()
+
+	if err := j.run(ctx); err != nil {
+		log.Printf("Failed to process %s: %v", j.name, err)
+	}
+}
+
+func (p *workerPool) submit(j *job) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return errPoolClosed
+	}
+
+	select {
+	case p.jobs <- j:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+func (p *workerPool) shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
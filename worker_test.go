@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolRecoversPanic(t *testing.T) {
+	p := newWorkerPool(1, 2)
+
+	recovered := make(chan struct{}, 1)
+	done := make(chan struct{}, 1)
+	jobs := []*job{
+		{
+			name:      "panic",
+			deadline:  time.Now().Add(time.Minute),
+			run:       func(ctx context.Context) error { panic("boom") },
+			recovered: func(ctx context.Context) { recovered <- struct{}{} },
+		},
+		{
+			name:     "next",
+			deadline: time.Now().Add(time.Minute),
+			run: func(ctx context.Context) error {
+				done <- struct{}{}
+				return nil
+			},
+		},
+	}
+	for _, j := range jobs {
+		if err := p.submit(j); err != nil {
+			t.Fatalf("failed to submit %s: %v", j.name, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.shutdown(ctx); err != nil {
+		t.Fatalf("failed to shut down: %v", err)
+	}
+
+	select {
+	case <-recovered:
+	default:
+		t.Error("the panicked job was not reported")
+	}
+	select {
+	case <-done:
+	default:
+		t.Error("the job queued after the panicked one was not run")
+	}
+}